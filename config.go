@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+)
+
+// configFile is the content of the file passed via the -config flag. It has
+// the same form of the configuration returned by GET /-/config. Missing fields
+// leave the corresponding flags at their default value.
+type configFile struct {
+	DurationMin      *float64 `json:"durationMin"`
+	DurationMax      *float64 `json:"durationMax"`
+	ErrorsPercentage *int     `json:"errorsPercentage"`
+	Capacity         *int     `json:"capacity"`
+	ClientErrorRatio *float64 `json:"clientErrorRatio"`
+	RequestRate      *int     `json:"requestRate"`
+	DurationScale    *float64 `json:"durationScale"`
+	DurationLambda   *float64 `json:"durationLambda"`
+
+	StatusDistribution limits.StatusDistribution `json:"statusDistribution"`
+}
+
+// setFlags returns the names of the flags explicitly set on the command line.
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	return set
+}
+
+// loadConfigFile reads the configuration file at path and applies it. Flags
+// explicitly set on the command line take precedence over the file.
+func (g *metricsGenerator) loadConfigFile(path string, set map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read configuration file: %v", err)
+	}
+
+	if err := g.applyConfigFile(data, set); err != nil {
+		return fmt.Errorf("configuration file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+func (g *metricsGenerator) applyConfigFile(data []byte, set map[string]bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file configFile
+
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	applyFloat64(&g.minDuration, file.DurationMin, set["duration-min"])
+	applyFloat64(&g.maxDuration, file.DurationMax, set["duration-max"])
+	applyInt(&g.errorsPercentage, file.ErrorsPercentage, set["errors-percentage"])
+	applyInt(&g.capacity, file.Capacity, set["capacity"])
+	applyFloat64(&g.clientErrorRatio, file.ClientErrorRatio, set["client-error-ratio"])
+	applyInt(&g.requestRate, file.RequestRate, set["request-rate"])
+	applyFloat64(&g.durationScale, file.DurationScale, set["duration-scale"])
+	applyFloat64(&g.durationLambda, file.DurationLambda, set["duration-lambda"])
+
+	// No flag sets the status distribution, so the file always applies.
+	g.statusDistribution = file.StatusDistribution
+
+	return nil
+}
+
+func applyFloat64(dst *float64, value *float64, overridden bool) {
+	if value != nil && !overridden {
+		*dst = *value
+	}
+}
+
+func applyInt(dst *int, value *int, overridden bool) {
+	if value != nil && !overridden {
+		*dst = *value
+	}
+}
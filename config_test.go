@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyConfigFile(t *testing.T) {
+	g := metricsGenerator{
+		minDuration:      1,
+		maxDuration:      10,
+		errorsPercentage: 10,
+		requestRate:      1,
+		durationScale:    1,
+	}
+
+	data := []byte(`{"durationMin":0.5,"durationMax":2,"errorsPercentage":20,"requestRate":5}`)
+
+	if err := g.applyConfigFile(data, map[string]bool{"errors-percentage": true}); err != nil {
+		t.Fatalf("apply configuration file: %v", err)
+	}
+
+	if g.minDuration != 0.5 || g.maxDuration != 2 {
+		t.Fatalf("invalid duration interval: %v,%v", g.minDuration, g.maxDuration)
+	}
+
+	// The flag set on the command line takes precedence over the file.
+	if g.errorsPercentage != 10 {
+		t.Fatalf("invalid errors percentage: %d", g.errorsPercentage)
+	}
+
+	if g.requestRate != 5 {
+		t.Fatalf("invalid request rate: %d", g.requestRate)
+	}
+
+	// Values missing from the file are left unchanged.
+	if g.durationScale != 1 {
+		t.Fatalf("invalid duration scale: %v", g.durationScale)
+	}
+}
+
+func TestApplyConfigFileStatusDistribution(t *testing.T) {
+	var g metricsGenerator
+
+	data := []byte(`{"statusDistribution":[{"code":503,"weight":1}]}`)
+
+	if err := g.applyConfigFile(data, nil); err != nil {
+		t.Fatalf("apply configuration file: %v", err)
+	}
+
+	if diff := cmp.Diff(g.statusDistribution, limits.StatusDistribution{{Code: 503, Weight: 1}}); diff != "" {
+		t.Fatalf("invalid status distribution:\n%s", diff)
+	}
+}
+
+func TestApplyConfigFileError(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "malformed",
+			data: `{"durationMin":`,
+		},
+		{
+			name: "invalid-type",
+			data: `{"errorsPercentage":"ten"}`,
+		},
+		{
+			name: "unknown-field",
+			data: `{"durationMinimum":1}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var g metricsGenerator
+
+			if err := g.applyConfigFile([]byte(test.data), nil); err == nil {
+				t.Fatalf("no error returned")
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileInvalidValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	if err := os.WriteFile(path, []byte(`{"durationMin":10,"durationMax":1}`), 0644); err != nil {
+		t.Fatalf("write configuration file: %v", err)
+	}
+
+	g := metricsGenerator{
+		errorsPercentage: 10,
+		requestRate:      1,
+		durationScale:    1,
+	}
+
+	if err := g.loadConfigFile(path, nil); err != nil {
+		t.Fatalf("load configuration file: %v", err)
+	}
+
+	// The values of the file are validated when the configuration is built.
+	if _, err := g.buildLimitsConfig(); err == nil {
+		t.Fatalf("no error returned")
+	}
+}
+
+func TestLoadConfigFileMissing(t *testing.T) {
+	var g metricsGenerator
+
+	if err := g.loadConfigFile(filepath.Join(t.TempDir(), "missing.json"), nil); err == nil {
+		t.Fatalf("no error returned")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables setting flags.
+const envPrefix = "METRICS_GENERATOR_"
+
+// envFlags are the flags that can be set via environment variables. The
+// variable for a flag is its name in upper case, with dashes replaced by
+// underscores, prefixed with envPrefix.
+var envFlags = []string{
+	"addr",
+	"duration-min",
+	"duration-max",
+	"errors-percentage",
+	"request-rate",
+	"auth-user",
+	"auth-pass",
+}
+
+// envName returns the name of the environment variable setting a flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets the flags in envFlags from the environment variables returned
+// by lookup. It must be called before the flags are parsed, so that the flags
+// on the command line take precedence over the environment.
+func applyEnv(fs *flag.FlagSet, lookup func(string) string) error {
+	for _, name := range envFlags {
+		value := lookup(envName(name))
+
+		if value == "" {
+			continue
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("environment variable %s: invalid value %q: %v", envName(name), value, err)
+		}
+	}
+
+	return nil
+}
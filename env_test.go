@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func newEnvFlagSet(g *metricsGenerator) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	fs.StringVar(&g.address, "addr", ":8080", "")
+	fs.Float64Var(&g.minDuration, "duration-min", 1, "")
+	fs.Float64Var(&g.maxDuration, "duration-max", 10, "")
+	fs.IntVar(&g.errorsPercentage, "errors-percentage", 10, "")
+	fs.IntVar(&g.requestRate, "request-rate", 1, "")
+
+	return fs
+}
+
+func envLookup(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	var g metricsGenerator
+
+	fs := newEnvFlagSet(&g)
+
+	env := map[string]string{
+		"METRICS_GENERATOR_ADDR":              ":9090",
+		"METRICS_GENERATOR_DURATION_MAX":      "20",
+		"METRICS_GENERATOR_ERRORS_PERCENTAGE": "30",
+	}
+
+	if err := applyEnv(fs, envLookup(env)); err != nil {
+		t.Fatalf("apply environment: %v", err)
+	}
+
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+
+	if g.address != ":9090" || g.maxDuration != 20 || g.errorsPercentage != 30 {
+		t.Fatalf("environment not applied: %+v", g)
+	}
+
+	if g.minDuration != 1 || g.requestRate != 1 {
+		t.Fatalf("defaults changed: %+v", g)
+	}
+}
+
+func TestApplyEnvFlagOverride(t *testing.T) {
+	var g metricsGenerator
+
+	fs := newEnvFlagSet(&g)
+
+	env := map[string]string{
+		"METRICS_GENERATOR_REQUEST_RATE": "5",
+	}
+
+	if err := applyEnv(fs, envLookup(env)); err != nil {
+		t.Fatalf("apply environment: %v", err)
+	}
+
+	if err := fs.Parse([]string{"-request-rate", "7"}); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+
+	if g.requestRate != 7 {
+		t.Fatalf("invalid request rate: %d", g.requestRate)
+	}
+}
+
+func TestApplyEnvInvalidValue(t *testing.T) {
+	var g metricsGenerator
+
+	fs := newEnvFlagSet(&g)
+
+	env := map[string]string{
+		"METRICS_GENERATOR_DURATION_MIN": "boom",
+	}
+
+	if err := applyEnv(fs, envLookup(env)); err == nil {
+		t.Fatalf("no error returned")
+	}
+}
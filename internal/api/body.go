@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"io"
+)
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// readBody reads the whole body, but gives up as soon as the context is done.
+// When that happens, the body is still being read in the background until the
+// read fails or completes, which happens at the latest when the server closes
+// the connection.
+func readBody(ctx context.Context, body io.Reader) ([]byte, error) {
+	done := make(chan readResult, 1)
+
+	go func() {
+		data, err := io.ReadAll(body)
+		done <- readResult{data: data, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.data, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// csvHeader is the first row of the CSV export of the metrics.
+var csvHeader = []string{"name", "labels", "value"}
+
+// writeMetricsCSV writes a row for every sample of the metric families, in the
+// same form used by the Prometheus exposition format: histograms and summaries
+// are split into their buckets or quantiles, their sum and their count.
+func writeMetricsCSV(w io.Writer, families []*dto.MetricFamily) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, row := range metricRows(family.GetName(), metric) {
+				if err := writer.Write(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func metricRows(name string, metric *dto.Metric) [][]string {
+	labels := metric.GetLabel()
+
+	switch {
+	case metric.Counter != nil:
+		return [][]string{csvRow(name, labels, metric.GetCounter().GetValue())}
+	case metric.Gauge != nil:
+		return [][]string{csvRow(name, labels, metric.GetGauge().GetValue())}
+	case metric.Untyped != nil:
+		return [][]string{csvRow(name, labels, metric.GetUntyped().GetValue())}
+	case metric.Histogram != nil:
+		var rows [][]string
+
+		for _, bucket := range metric.GetHistogram().GetBucket() {
+			rows = append(rows, csvRow(name+"_bucket", withLabel(labels, "le", formatFloat(bucket.GetUpperBound())), float64(bucket.GetCumulativeCount())))
+		}
+
+		histogram := metric.GetHistogram()
+
+		return append(rows,
+			csvRow(name+"_bucket", withLabel(labels, "le", "+Inf"), float64(histogram.GetSampleCount())),
+			csvRow(name+"_sum", labels, histogram.GetSampleSum()),
+			csvRow(name+"_count", labels, float64(histogram.GetSampleCount())),
+		)
+	case metric.Summary != nil:
+		var rows [][]string
+
+		for _, quantile := range metric.GetSummary().GetQuantile() {
+			rows = append(rows, csvRow(name, withLabel(labels, "quantile", formatFloat(quantile.GetQuantile())), quantile.GetValue()))
+		}
+
+		summary := metric.GetSummary()
+
+		return append(rows,
+			csvRow(name+"_sum", labels, summary.GetSampleSum()),
+			csvRow(name+"_count", labels, float64(summary.GetSampleCount())),
+		)
+	default:
+		return nil
+	}
+}
+
+func withLabel(labels []*dto.LabelPair, name, value string) []*dto.LabelPair {
+	result := append([]*dto.LabelPair(nil), labels...)
+	return append(result, &dto.LabelPair{Name: &name, Value: &value})
+}
+
+func csvRow(name string, labels []*dto.LabelPair, value float64) []string {
+	return []string{name, formatLabels(labels), formatFloat(value)}
+}
+
+// formatLabels formats the labels like the Prometheus exposition format,
+// without the braces, sorted by name.
+func formatLabels(labels []*dto.LabelPair) string {
+	var pairs []string
+
+	for _, label := range labels {
+		pairs = append(pairs, label.GetName()+"="+strconv.Quote(label.GetValue()))
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -1,27 +1,233 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/francescomari/metrics-generator/internal/limits"
+	"github.com/francescomari/metrics-generator/internal/version"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-type Config interface {
+// Config is the configuration exposed by the Handler. The Handler serves the
+// endpoints for a part of the configuration only if Config implements the
+// corresponding interface among DurationConfig, DurationSecondsConfig,
+// ScaleConfig, LambdaConfig, ErrorsConfig, VersionedErrorsConfig, RateConfig,
+// StatusDistributionConfig, SnapshotConfig and UpdateConfig.
+type Config interface{}
+
+type DurationConfig interface {
 	DurationInterval() (int, int)
 	SetDurationInterval(min, max int) error
+}
+
+// DurationSecondsConfig is like DurationConfig, but supports fractions of a
+// second. If Config implements both, DurationSecondsConfig is preferred.
+type DurationSecondsConfig interface {
+	DurationIntervalSeconds() (float64, float64)
+	SetDurationIntervalSeconds(min, max float64) error
+}
+
+type ScaleConfig interface {
+	DurationScale() float64
+	SetDurationScale(value float64) error
+}
+
+type LambdaConfig interface {
+	DurationLambda() float64
+	SetDurationLambda(value float64) error
+}
+
+type ErrorsConfig interface {
 	ErrorsPercentage() int
 	SetErrorsPercentage(value int) error
 }
 
+// VersionedErrorsConfig returns the errors percentage together with a version
+// that changes every time the errors percentage changes. If Config implements
+// it in addition to ErrorsConfig, the errors percentage is served with an ETag.
+type VersionedErrorsConfig interface {
+	VersionedErrorsPercentage() (int, uint64)
+}
+
+type RateConfig interface {
+	RequestRate() int
+	SetRequestRate(value int) error
+}
+
+type StatusDistributionConfig interface {
+	StatusDistribution() limits.StatusDistribution
+	SetStatusDistribution(value limits.StatusDistribution) error
+}
+
+type SnapshotConfig interface {
+	Snapshot() limits.Settings
+}
+
+type UpdateConfig interface {
+	Update(update func(limits.Settings) (limits.Settings, error)) error
+}
+
+// RateHistory returns the most recent request rates, from the oldest to the
+// most recent.
+type RateHistory interface {
+	Rates() []float64
+}
+
+// Readiness tells whether the process is ready to serve meaningful metrics.
+type Readiness interface {
+	Ready() bool
+}
+
+// redactedFlagNames are the substrings that, when found in the name of a flag,
+// cause its value to be hidden from the flags endpoint.
+var redactedFlagNames = []string{"pass", "token", "secret"}
+
+const redactedFlagValue = "[REDACTED]"
+
+// defaultMetricsPath is the path the metrics are served from if no other path
+// is specified.
+const defaultMetricsPath = "/metrics"
+
 type Handler struct {
 	Config  Config
 	Metrics http.Handler
 
+	// MetricsPath is the path the metrics are served from. If MetricsPath is
+	// empty, the metrics are served from /metrics.
+	MetricsPath string
+
+	// Gatherer, if not nil, is the source of the metrics exported as CSV.
+	Gatherer prometheus.Gatherer
+
+	// MetricsDelay, if greater than zero, delays the responses of the metrics
+	// endpoint to simulate a slow collector.
+	MetricsDelay time.Duration
+
+	Flags    *flag.FlagSet
+	Shutdown func()
+	AuthUser string
+	AuthPass string
+
+	// RateHistory, if not nil, is served by the request rate history endpoint.
+	RateHistory RateHistory
+
+	// ReadinessGrace is how long the readiness endpoint reports that the
+	// process is not ready after a disruptive change of the configuration,
+	// like a change of the duration interval, to give dashboards time to
+	// settle. If ReadinessGrace is zero, the process is always ready.
+	ReadinessGrace time.Duration
+
+	// Readiness, if not nil, makes the readiness endpoint report that the
+	// process is not ready until Readiness is ready.
+	Readiness Readiness
+
+	// StartupDelay is how long after Started the health and readiness
+	// endpoints report that the process is unavailable, to simulate a
+	// slow-starting application. If StartupDelay is zero, the process is
+	// healthy right away.
+	StartupDelay time.Duration
+	Started      time.Time
+
+	// Logger, if not nil, logs the method, the path, the status code and the
+	// duration of every request.
+	Logger *slog.Logger
+
+	// Now returns the current time. If Now is nil, time.Now is used.
+	Now func() time.Time
+
 	once    sync.Once
 	handler http.Handler
+
+	mu                   sync.RWMutex
+	metrics              http.Handler
+	maintenance          bool
+	lastDisruptiveChange time.Time
+}
+
+type maintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetMetrics replaces the handler serving the metrics, which is initially
+// Metrics. SetMetrics can be called while the Handler is serving requests.
+func (h *Handler) SetMetrics(metrics http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.metrics = metrics
+}
+
+func (h *Handler) metricsHandler() http.Handler {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.metrics != nil {
+		return h.metrics
+	}
+
+	return h.Metrics
+}
+
+func (h *Handler) inMaintenance() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.maintenance
+}
+
+func (h *Handler) setMaintenance(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.maintenance = enabled
+}
+
+func (h *Handler) now() time.Time {
+	if h.Now == nil {
+		return time.Now()
+	}
+
+	return h.Now()
+}
+
+// disruptiveChange records that the configuration changed in a way that makes
+// the metrics inconsistent for a while.
+func (h *Handler) disruptiveChange() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastDisruptiveChange = h.now()
+}
+
+func (h *Handler) isStarting() bool {
+	return h.now().Sub(h.Started) < h.StartupDelay
+}
+
+func (h *Handler) isSettling() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.lastDisruptiveChange.IsZero() {
+		return false
+	}
+
+	return h.now().Sub(h.lastDisruptiveChange) < h.ReadinessGrace
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -33,11 +239,24 @@ func (h *Handler) setupHandlers() {
 	router := mux.NewRouter()
 
 	h.setupHealthHandler(router)
+	h.setupReadyHandler(router)
+	h.setupVersionHandler(router)
+	h.setupMaintenanceHandlers(router)
+	h.setupFlagsHandler(router)
+	h.setupShutdownHandler(router)
+	h.setupConfigHandlers(router)
 	h.setupDurationIntervalHandlers(router)
+	h.setupDurationScaleHandlers(router)
+	h.setupDurationLambdaHandlers(router)
 	h.setupErrorsPercentageHandlers(router)
+	h.setupRequestRateHandlers(router)
+	h.setupRequestRateHistoryHandler(router)
+	h.setupStatusDistributionHandlers(router)
 	h.setupMetricsHandler(router)
+	h.setupMetricsCSVHandler(router)
+	h.setupFaviconHandler(router)
 
-	h.handler = router
+	h.handler = h.logRequests(h.requireConfigAuth(router))
 }
 
 func (h *Handler) setupHealthHandler(router *mux.Router) {
@@ -45,9 +264,101 @@ func (h *Handler) setupHealthHandler(router *mux.Router) {
 		Methods(http.MethodGet).
 		Path("/-/health").
 		HandlerFunc(h.handleHealth)
+
+	router.
+		Methods(http.MethodGet).
+		Path("/-/live").
+		HandlerFunc(h.handleLive)
+}
+
+func (h *Handler) setupReadyHandler(router *mux.Router) {
+	router.
+		Methods(http.MethodGet).
+		Path("/-/ready").
+		HandlerFunc(h.handleReady)
+}
+
+func (h *Handler) setupVersionHandler(router *mux.Router) {
+	router.
+		Methods(http.MethodGet).
+		Path("/-/version").
+		HandlerFunc(h.handleVersion)
+}
+
+func (h *Handler) setupMaintenanceHandlers(router *mux.Router) {
+	sub := router.
+		PathPrefix("/-/maintenance").
+		Subrouter()
+
+	sub.
+		Methods(http.MethodGet).
+		HandlerFunc(h.handleGetMaintenance)
+
+	// Maintenance mode drains the instance from the load balancers, so
+	// changing it requires the same authentication as the configuration.
+	sub.
+		Methods(http.MethodPut).
+		Handler(h.requireAuth(http.HandlerFunc(h.handleSetMaintenance)))
+}
+
+func (h *Handler) setupFlagsHandler(router *mux.Router) {
+	if h.Flags == nil {
+		return
+	}
+
+	router.
+		Methods(http.MethodGet).
+		Path("/-/flags").
+		HandlerFunc(h.handleFlags)
+}
+
+func (h *Handler) setupShutdownHandler(router *mux.Router) {
+	if h.Shutdown == nil {
+		return
+	}
+
+	router.
+		Methods(http.MethodPost).
+		Path("/-/shutdown").
+		Handler(h.requireAuth(http.HandlerFunc(h.handleShutdown)))
+}
+
+func (h *Handler) setupConfigHandlers(router *mux.Router) {
+	if _, ok := h.Config.(SnapshotConfig); ok {
+		router.
+			Methods(http.MethodGet).
+			Path("/-/config").
+			HandlerFunc(h.handleGetConfig)
+
+		router.
+			Methods(http.MethodGet).
+			Path("/-/config/metrics").
+			HandlerFunc(h.handleGetConfigMetrics)
+	}
+
+	if _, ok := h.Config.(UpdateConfig); !ok {
+		return
+	}
+
+	router.
+		Methods(http.MethodPut).
+		Path("/-/config").
+		HandlerFunc(h.handleSetConfig)
+
+	router.
+		Methods(http.MethodPatch).
+		Path("/-/config").
+		HandlerFunc(h.handlePatchConfig)
 }
 
 func (h *Handler) setupDurationIntervalHandlers(router *mux.Router) {
+	_, ok := h.Config.(DurationConfig)
+	_, okSeconds := h.Config.(DurationSecondsConfig)
+
+	if !ok && !okSeconds {
+		return
+	}
+
 	sub := router.
 		PathPrefix("/-/config/duration-interval").
 		Subrouter()
@@ -61,7 +372,47 @@ func (h *Handler) setupDurationIntervalHandlers(router *mux.Router) {
 		HandlerFunc(h.handleSetDurationInterval)
 }
 
+func (h *Handler) setupDurationScaleHandlers(router *mux.Router) {
+	if _, ok := h.Config.(ScaleConfig); !ok {
+		return
+	}
+
+	sub := router.
+		PathPrefix("/-/config/duration-scale").
+		Subrouter()
+
+	sub.
+		Methods(http.MethodGet).
+		HandlerFunc(h.handleGetDurationScale)
+
+	sub.
+		Methods(http.MethodPut).
+		HandlerFunc(h.handleSetDurationScale)
+}
+
+func (h *Handler) setupDurationLambdaHandlers(router *mux.Router) {
+	if _, ok := h.Config.(LambdaConfig); !ok {
+		return
+	}
+
+	sub := router.
+		PathPrefix("/-/config/duration-lambda").
+		Subrouter()
+
+	sub.
+		Methods(http.MethodGet).
+		HandlerFunc(h.handleGetDurationLambda)
+
+	sub.
+		Methods(http.MethodPut).
+		HandlerFunc(h.handleSetDurationLambda)
+}
+
 func (h *Handler) setupErrorsPercentageHandlers(router *mux.Router) {
+	if _, ok := h.Config.(ErrorsConfig); !ok {
+		return
+	}
+
 	sub := router.
 		PathPrefix("/-/config/errors-percentage").
 		Subrouter()
@@ -73,53 +424,488 @@ func (h *Handler) setupErrorsPercentageHandlers(router *mux.Router) {
 	sub.
 		Methods(http.MethodPut).
 		HandlerFunc(h.handleSetErrorsPercentage)
+
+	if _, ok := h.Config.(UpdateConfig); !ok {
+		return
+	}
+
+	sub.
+		Methods(http.MethodPost).
+		Path("/increment").
+		HandlerFunc(h.handleIncrementErrorsPercentage)
+
+	sub.
+		Methods(http.MethodPost).
+		Path("/decrement").
+		HandlerFunc(h.handleDecrementErrorsPercentage)
+}
+
+func (h *Handler) setupRequestRateHandlers(router *mux.Router) {
+	if _, ok := h.Config.(RateConfig); !ok {
+		return
+	}
+
+	sub := router.
+		PathPrefix("/-/config/request-rate").
+		Subrouter()
+
+	// The empty path matches only the prefix, leaving the paths below it, like
+	// the one of the request rate history, to other handlers.
+	sub.
+		Methods(http.MethodGet).
+		Path("").
+		HandlerFunc(h.handleGetRequestRate)
+
+	sub.
+		Methods(http.MethodPut).
+		Path("").
+		HandlerFunc(h.handleSetRequestRate)
+}
+
+func (h *Handler) setupRequestRateHistoryHandler(router *mux.Router) {
+	if h.RateHistory == nil {
+		return
+	}
+
+	router.
+		Methods(http.MethodGet).
+		Path("/-/config/request-rate/history").
+		HandlerFunc(h.handleGetRequestRateHistory)
+}
+
+func (h *Handler) setupStatusDistributionHandlers(router *mux.Router) {
+	if _, ok := h.Config.(StatusDistributionConfig); !ok {
+		return
+	}
+
+	sub := router.
+		PathPrefix("/-/config/status-distribution").
+		Subrouter()
+
+	sub.
+		Methods(http.MethodGet).
+		HandlerFunc(h.handleGetStatusDistribution)
+
+	sub.
+		Methods(http.MethodPut).
+		HandlerFunc(h.handleSetStatusDistribution)
 }
 
 func (h *Handler) setupMetricsHandler(router *mux.Router) {
 	router.
 		Methods(http.MethodGet).
-		Path("/metrics").
-		Handler(h.Metrics)
+		Path(h.metricsPath()).
+		Handler(h.delay(http.HandlerFunc(h.handleMetrics)))
+}
+
+func (h *Handler) setupMetricsCSVHandler(router *mux.Router) {
+	if h.Gatherer == nil {
+		return
+	}
+
+	router.
+		Methods(http.MethodGet).
+		Path("/-/metrics.csv").
+		HandlerFunc(h.handleMetricsCSV)
+}
+
+func (h *Handler) setupFaviconHandler(router *mux.Router) {
+	router.
+		Methods(http.MethodGet).
+		Path("/favicon.ico").
+		HandlerFunc(h.handleFavicon)
+}
+
+func (h *Handler) metricsPath() string {
+	if h.MetricsPath == "" {
+		return defaultMetricsPath
+	}
+
+	return h.MetricsPath
 }
 
+func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := h.metricsHandler()
+
+	if metrics == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	metrics.ServeHTTP(w, r)
+}
+
+// handleLive reports that the process is up. It never fails, so that a
+// liveness probe doesn't restart the process because of maintenance mode or a
+// slow startup.
+func (h *Handler) handleLive(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "OK")
+}
+
+// handleHealth is the legacy health check, which fails while the process is
+// starting or in maintenance mode.
 func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if reason, ok := h.unavailable(); ok {
+		httpError(w, http.StatusServiceUnavailable, reason)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
+// unavailable returns why the process is unavailable, if it is starting or in
+// maintenance mode.
+func (h *Handler) unavailable() (string, bool) {
+	if h.isStarting() {
+		return "starting", true
+	}
+
+	if h.inMaintenance() {
+		return "maintenance", true
+	}
+
+	return "", false
+}
+
+func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	if reason, ok := h.unavailable(); ok {
+		httpError(w, http.StatusServiceUnavailable, reason)
+		return
+	}
+
+	if h.Readiness != nil && !h.Readiness.Ready() {
+		httpError(w, http.StatusServiceUnavailable, "not generating metrics")
+		return
+	}
+
+	if h.isSettling() {
+		httpError(w, http.StatusServiceUnavailable, "settling after a configuration change")
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
+func (h *Handler) handleVersion(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, version.String())
+}
+
+func (h *Handler) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, maintenanceStatus{Enabled: h.inMaintenance()})
+}
+
+func (h *Handler) handleSetMaintenance(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r.Context(), r.Body)
+	if err != nil {
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
+		return
+	}
+
+	var status maintenanceStatus
+
+	if err := json.Unmarshal(data, &status); err != nil {
+		httpError(w, http.StatusBadRequest, "parse maintenance status: %v", err)
+		return
+	}
+
+	h.setMaintenance(status.Enabled)
+
+	fmt.Fprintln(w, "OK")
+}
+
+func (h *Handler) handleMetricsCSV(w http.ResponseWriter, r *http.Request) {
+	families, err := h.Gatherer.Gather()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, "gather metrics: %v", err)
+		return
+	}
+
+	var buffer bytes.Buffer
+
+	if err := writeMetricsCSV(&buffer, families); err != nil {
+		httpError(w, http.StatusInternalServerError, "write CSV: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Write(buffer.Bytes())
+}
+
+func (h *Handler) handleFavicon(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleFlags(w http.ResponseWriter, r *http.Request) {
+	flags := make(map[string]string)
+
+	h.Flags.VisitAll(func(f *flag.Flag) {
+		if isRedactedFlag(f.Name) {
+			flags[f.Name] = redactedFlagValue
+		} else {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+
+	writeJSON(w, flags)
+}
+
+func (h *Handler) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "OK")
+	h.Shutdown()
+}
+
+func (h *Handler) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.Config.(SnapshotConfig).Snapshot())
+}
+
+func (h *Handler) handleGetConfigMetrics(w http.ResponseWriter, r *http.Request) {
+	registry := prometheus.NewRegistry()
+
+	for _, collector := range settingsCollectors(h.Config.(SnapshotConfig).Snapshot()) {
+		if err := registry.Register(collector); err != nil {
+			httpError(w, http.StatusInternalServerError, "register gauge: %v", err)
+			return
+		}
+	}
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// settingsCollectors returns a gauge for every value of the settings, and a
+// gauge partitioned by status code for the status distribution.
+func settingsCollectors(settings limits.Settings) []prometheus.Collector {
+	values := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"config_duration_min_seconds", "Minimum duration of the requests", settings.MinDuration},
+		{"config_duration_max_seconds", "Maximum duration of the requests", settings.MaxDuration},
+		{"config_errors_percentage", "Percentage of the requests that fail", float64(settings.ErrorsPercentage)},
+		{"config_capacity", "Request rate the simulated service can sustain", float64(settings.Capacity)},
+		{"config_client_error_ratio", "Fraction of the failed requests that are client errors", settings.ClientErrorRatio},
+		{"config_request_rate", "Number of requests per second", float64(settings.RequestRate)},
+		{"config_duration_scale", "Factor applied to the duration of the requests", settings.DurationScale},
+		{"config_duration_lambda", "Rate parameter of the exponential distribution of the durations", settings.DurationLambda},
+	}
+
+	var collectors []prometheus.Collector
+
+	for _, v := range values {
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: v.name,
+			Help: v.help,
+		})
+
+		gauge.Set(v.value)
+		collectors = append(collectors, gauge)
+	}
+
+	weights := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "config_status_weight",
+		Help: "Weight of the status codes of the failed requests",
+	}, []string{"code"})
+
+	for _, w := range settings.StatusDistribution {
+		weights.WithLabelValues(strconv.Itoa(w.Code)).Set(float64(w.Weight))
+	}
+
+	return append(collectors, weights)
+}
+
+func (h *Handler) handleSetConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r.Context(), r.Body)
+	if err != nil {
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
+		return
+	}
+
+	settings, err := parseSettings(data)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "parse configuration: %v", err)
+		return
+	}
+
+	err = h.Config.(UpdateConfig).Update(func(limits.Settings) (limits.Settings, error) {
+		return settings, nil
+	})
+
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "set configuration: %v", err)
+		return
+	}
+
+	h.disruptiveChange()
+
+	fmt.Fprintln(w, "OK")
+}
+
+func (h *Handler) handlePatchConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r.Context(), r.Body)
+	if err != nil {
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
+		return
+	}
+
+	operations, err := parsePatch(data)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "parse patch: %v", err)
+		return
+	}
+
+	err = h.Config.(UpdateConfig).Update(func(settings limits.Settings) (limits.Settings, error) {
+		return applyPatch(settings, operations)
+	})
+
+	var testFailed errPatchTestFailed
+
+	switch {
+	case errors.As(err, &testFailed):
+		httpError(w, http.StatusConflict, "apply patch: %v", err)
+		return
+	case err != nil:
+		httpError(w, http.StatusBadRequest, "apply patch: %v", err)
+		return
+	}
+
+	h.disruptiveChange()
+
 	fmt.Fprintln(w, "OK")
 }
 
 func (h *Handler) handleGetDurationInterval(w http.ResponseWriter, r *http.Request) {
-	min, max := h.Config.DurationInterval()
-	fmt.Fprintf(w, "%d,%d\n", min, max)
+	min, max := h.durationInterval()
+	fmt.Fprintf(w, "%s,%s\n", formatFloat(min), formatFloat(max))
 }
 
 func (h *Handler) handleSetDurationInterval(w http.ResponseWriter, r *http.Request) {
-	data, err := io.ReadAll(r.Body)
+	data, err := readBody(r.Context(), r.Body)
 	if err != nil {
-		httpError(w, http.StatusInternalServerError, "read body: %v", err)
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
 		return
 	}
 
 	min, max, err := parseDurationInterval(string(data))
 	if err != nil {
-		httpError(w, http.StatusBadRequest, "parse duration interval: %v", err)
+		httpValueError(w, err)
 		return
 	}
 
-	if err := h.Config.SetDurationInterval(min, max); err != nil {
+	if err := h.setDurationInterval(min, max); err != nil {
 		httpError(w, http.StatusBadRequest, "set duration interval: %v", err)
 		return
 	}
 
+	h.disruptiveChange()
+
+	fmt.Fprintln(w, "OK")
+}
+
+// durationInterval returns the duration interval from DurationSecondsConfig,
+// if implemented, or from DurationConfig.
+func (h *Handler) durationInterval() (float64, float64) {
+	if config, ok := h.Config.(DurationSecondsConfig); ok {
+		return config.DurationIntervalSeconds()
+	}
+
+	min, max := h.Config.(DurationConfig).DurationInterval()
+	return float64(min), float64(max)
+}
+
+// setDurationInterval sets the duration interval via DurationSecondsConfig, if
+// implemented, or via DurationConfig, which only accepts whole seconds.
+func (h *Handler) setDurationInterval(min, max float64) error {
+	if config, ok := h.Config.(DurationSecondsConfig); ok {
+		return config.SetDurationIntervalSeconds(min, max)
+	}
+
+	if min != math.Trunc(min) || max != math.Trunc(max) {
+		return fmt.Errorf("fractions of a second are not supported")
+	}
+
+	return h.Config.(DurationConfig).SetDurationInterval(int(min), int(max))
+}
+
+func (h *Handler) handleGetDurationScale(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%s\n", formatFloat(h.Config.(ScaleConfig).DurationScale()))
+}
+
+func (h *Handler) handleSetDurationScale(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r.Context(), r.Body)
+	if err != nil {
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
+		return
+	}
+
+	value, err := parseFloat(string(data))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "parse duration scale: %v", err)
+		return
+	}
+
+	if err := h.Config.(ScaleConfig).SetDurationScale(value); err != nil {
+		httpError(w, http.StatusBadRequest, "set duration scale: %v", err)
+		return
+	}
+
+	h.disruptiveChange()
+
+	fmt.Fprintln(w, "OK")
+}
+
+func (h *Handler) handleGetDurationLambda(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%s\n", formatFloat(h.Config.(LambdaConfig).DurationLambda()))
+}
+
+func (h *Handler) handleSetDurationLambda(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r.Context(), r.Body)
+	if err != nil {
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
+		return
+	}
+
+	value, err := parseFloat(string(data))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "parse duration lambda: %v", err)
+		return
+	}
+
+	if err := h.Config.(LambdaConfig).SetDurationLambda(value); err != nil {
+		httpError(w, http.StatusBadRequest, "set duration lambda: %v", err)
+		return
+	}
+
+	h.disruptiveChange()
+
 	fmt.Fprintln(w, "OK")
 }
 
 func (h *Handler) handleGetErrorsPercentage(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "%d\n", h.Config.ErrorsPercentage())
+	config, ok := h.Config.(VersionedErrorsConfig)
+	if !ok {
+		fmt.Fprintf(w, "%d\n", h.Config.(ErrorsConfig).ErrorsPercentage())
+		return
+	}
+
+	value, version := config.VersionedErrorsPercentage()
+
+	etag := fmt.Sprintf(`"%d-%d"`, value, version)
+
+	w.Header().Set("ETag", etag)
+
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	fmt.Fprintf(w, "%d\n", value)
 }
 
 func (h *Handler) handleSetErrorsPercentage(w http.ResponseWriter, r *http.Request) {
-	data, err := io.ReadAll(r.Body)
+	data, err := readBody(r.Context(), r.Body)
 	if err != nil {
-		httpError(w, http.StatusInternalServerError, "read body: %v", err)
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
 		return
 	}
 
@@ -129,7 +915,7 @@ func (h *Handler) handleSetErrorsPercentage(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.Config.SetErrorsPercentage(value); err != nil {
+	if err := h.Config.(ErrorsConfig).SetErrorsPercentage(value); err != nil {
 		httpError(w, http.StatusBadRequest, "set errors percentage: %v", err)
 		return
 	}
@@ -137,6 +923,270 @@ func (h *Handler) handleSetErrorsPercentage(w http.ResponseWriter, r *http.Reque
 	fmt.Fprintln(w, "OK")
 }
 
+func (h *Handler) handleIncrementErrorsPercentage(w http.ResponseWriter, r *http.Request) {
+	h.adjustErrorsPercentage(w, r, 1)
+}
+
+func (h *Handler) handleDecrementErrorsPercentage(w http.ResponseWriter, r *http.Request) {
+	h.adjustErrorsPercentage(w, r, -1)
+}
+
+// adjustErrorsPercentage adds the step in the body of the request, multiplied
+// by sign, to the errors percentage. The result is clamped between 0 and 100.
+func (h *Handler) adjustErrorsPercentage(w http.ResponseWriter, r *http.Request, sign int) {
+	data, err := readBody(r.Context(), r.Body)
+	if err != nil {
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
+		return
+	}
+
+	step, err := parseStep(data)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "parse step: %v", err)
+		return
+	}
+
+	var value int
+
+	err = h.Config.(UpdateConfig).Update(func(settings limits.Settings) (limits.Settings, error) {
+		settings.ErrorsPercentage = clampPercentage(settings.ErrorsPercentage + sign*step)
+		value = settings.ErrorsPercentage
+		return settings, nil
+	})
+
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "set errors percentage: %v", err)
+		return
+	}
+
+	fmt.Fprintf(w, "%d\n", value)
+}
+
+func (h *Handler) handleGetRequestRate(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%d\n", h.Config.(RateConfig).RequestRate())
+}
+
+func (h *Handler) handleSetRequestRate(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r.Context(), r.Body)
+	if err != nil {
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
+		return
+	}
+
+	value, err := parseInt(string(data))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "parse request rate: %v", err)
+		return
+	}
+
+	if err := h.Config.(RateConfig).SetRequestRate(value); err != nil {
+		httpError(w, http.StatusBadRequest, "set request rate: %v", err)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
+func (h *Handler) handleGetRequestRateHistory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.RateHistory.Rates())
+}
+
+func (h *Handler) handleGetStatusDistribution(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.Config.(StatusDistributionConfig).StatusDistribution())
+}
+
+func (h *Handler) handleSetStatusDistribution(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r.Context(), r.Body)
+	if err != nil {
+		httpError(w, readBodyErrorCode(err), "read body: %v", err)
+		return
+	}
+
+	var distribution limits.StatusDistribution
+
+	if err := json.Unmarshal(data, &distribution); err != nil {
+		httpError(w, http.StatusBadRequest, "parse status distribution: %v", err)
+		return
+	}
+
+	if err := h.Config.(StatusDistributionConfig).SetStatusDistribution(distribution); err != nil {
+		httpError(w, http.StatusBadRequest, "set status distribution: %v", err)
+		return
+	}
+
+	h.disruptiveChange()
+
+	fmt.Fprintln(w, "OK")
+}
+
+func (h *Handler) requireAuth(next http.Handler) http.Handler {
+	if h.AuthUser == "" && h.AuthPass == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.isAuthorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics-generator"`)
+			httpError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) delay(next http.Handler) http.Handler {
+	if h.MetricsDelay <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timer := time.NewTimer(h.MetricsDelay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) logRequests(next http.Handler) http.Handler {
+	if h.Logger == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := h.now()
+
+		recorder := statusRecorder{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		next.ServeHTTP(&recorder, r)
+
+		h.Logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration", h.now().Sub(start),
+		)
+	})
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// requireConfigAuth requires authentication for the configuration endpoints,
+// which can change the behaviour of the process.
+func (h *Handler) requireConfigAuth(next http.Handler) http.Handler {
+	authenticated := h.requireAuth(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isConfigPath(r.URL.Path) {
+			authenticated.ServeHTTP(w, r)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func isConfigPath(p string) bool {
+	p = path.Clean(p)
+	return p == "/-/config" || strings.HasPrefix(p, "/-/config/")
+}
+
+func (h *Handler) isAuthorized(r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	userMatches := subtle.ConstantTimeCompare([]byte(user), []byte(h.AuthUser)) == 1
+	passMatches := subtle.ConstantTimeCompare([]byte(pass), []byte(h.AuthPass)) == 1
+
+	return userMatches && passMatches
+}
+
+// matchesETag returns true if the value of an If-None-Match header matches the
+// given entity tag, using the weak comparison defined in RFC 7232.
+func matchesETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isRedactedFlag(name string) bool {
+	for _, redacted := range redactedFlagNames {
+		if strings.Contains(name, redacted) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readBodyErrorCode(err error) int {
+	switch err {
+	case context.Canceled, context.DeadlineExceeded:
+		return http.StatusRequestTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, "encode JSON: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
 func httpError(w http.ResponseWriter, code int, format string, args ...interface{}) {
 	http.Error(w, fmt.Sprintf(format, args...), code)
 }
+
+// httpValueError replies with a 400 response. If err is a valueError, the body
+// of the response is a JSON object reporting the error and the offending value.
+func httpValueError(w http.ResponseWriter, err error) {
+	var valueErr valueError
+
+	if !errors.As(err, &valueErr) {
+		httpError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	data, err := json.Marshal(valueErr)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, "encode JSON: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(append(data, '\n'))
+}
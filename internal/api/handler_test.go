@@ -1,30 +1,46 @@
 package api_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"strings"
 	"testing"
 	"testing/iotest"
+	"time"
 
 	"github.com/francescomari/metrics-generator/internal/api"
+	"github.com/francescomari/metrics-generator/internal/limits"
+	"github.com/francescomari/metrics-generator/internal/version"
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
 type mockConfig struct {
-	doDurationInterval    func() (int, int)
-	doSetDurationInterval func(min, max int) error
-	doErrorsPercentage    func() int
-	doSetErrorsPercentage func(value int) error
+	doDurationInterval          func() (float64, float64)
+	doSetDurationInterval       func(min, max float64) error
+	doErrorsPercentage          func() int
+	doVersionedErrorsPercentage func() (int, uint64)
+	doSetErrorsPercentage       func(value int) error
+	doRequestRate               func() int
+	doSetRequestRate            func(value int) error
+	doUpdate                    func(update func(limits.Settings) (limits.Settings, error)) error
 }
 
-func (c mockConfig) DurationInterval() (int, int) {
+func (c mockConfig) DurationIntervalSeconds() (float64, float64) {
 	return c.doDurationInterval()
 }
 
-func (c mockConfig) SetDurationInterval(min, max int) error {
+func (c mockConfig) SetDurationIntervalSeconds(min, max float64) error {
 	return c.doSetDurationInterval(min, max)
 }
 
@@ -32,135 +48,1356 @@ func (c mockConfig) ErrorsPercentage() int {
 	return c.doErrorsPercentage()
 }
 
+func (c mockConfig) VersionedErrorsPercentage() (int, uint64) {
+	return c.doVersionedErrorsPercentage()
+}
+
 func (c mockConfig) SetErrorsPercentage(value int) error {
 	return c.doSetErrorsPercentage(value)
 }
 
-func TestHandlerHealth(t *testing.T) {
-	handler := api.Handler{}
+func (c mockConfig) RequestRate() int {
+	return c.doRequestRate()
+}
+
+func (c mockConfig) SetRequestRate(value int) error {
+	return c.doSetRequestRate(value)
+}
+
+func (c mockConfig) Update(update func(limits.Settings) (limits.Settings, error)) error {
+	return c.doUpdate(update)
+}
+
+// durationConfig implements only the integer duration interval part of the
+// configuration.
+type durationConfig struct {
+	min, max int
+}
+
+func (c *durationConfig) DurationInterval() (int, int) {
+	return c.min, c.max
+}
+
+func (c *durationConfig) SetDurationInterval(min, max int) error {
+	c.min, c.max = min, max
+	return nil
+}
+
+func TestHandlerPartialConfig(t *testing.T) {
+	handler := handlerForConfig(&durationConfig{min: 1, max: 10})
+
+	checkStatusCode(t, doGetDurationIntervalRequest(handler), http.StatusOK)
+	checkStatusCode(t, doGetErrorsPercentageRequest(handler), http.StatusNotFound)
+	checkStatusCode(t, doGetRequestRateRequest(handler), http.StatusNotFound)
+	checkStatusCode(t, doGetDurationScaleRequest(handler), http.StatusNotFound)
+	checkStatusCode(t, doGetStatusDistributionRequest(handler), http.StatusNotFound)
+	checkStatusCode(t, doPatchConfigRequest(handler, strings.NewReader("[]")), http.StatusNotFound)
+	checkStatusCode(t, doGetConfigRequest(handler), http.StatusNotFound)
+}
+
+func TestHandlerHealth(t *testing.T) {
+	handler := api.Handler{}
+
+	response := doHealthRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+}
+
+func TestHandlerVersion(t *testing.T) {
+	defer func(v, r, d string) {
+		version.Version, version.Revision, version.BuildDate = v, r, d
+	}(version.Version, version.Revision, version.BuildDate)
+
+	version.Version = "1.2.3"
+	version.Revision = "abcdef"
+	version.BuildDate = "2021-01-02"
+
+	response := doRequest(&api.Handler{}, http.MethodGet, "/-/version")
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, fmt.Sprintf("version 1.2.3, revision abcdef, built 2021-01-02 with %s\n", runtime.Version()))
+}
+
+func TestHandlerHealthStartupDelay(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	handler := api.Handler{
+		StartupDelay: 10 * time.Second,
+		Started:      now,
+		Now: func() time.Time {
+			return now
+		},
+	}
+
+	checkStatusCode(t, doHealthRequest(&handler), http.StatusServiceUnavailable)
+
+	now = now.Add(9 * time.Second)
+	checkStatusCode(t, doHealthRequest(&handler), http.StatusServiceUnavailable)
+
+	now = now.Add(time.Second)
+	checkStatusCode(t, doHealthRequest(&handler), http.StatusOK)
+}
+
+func TestHandlerMetrics(t *testing.T) {
+	handler := api.Handler{
+		Metrics: textHandler("old"),
+	}
+
+	response := doMetricsRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "old")
+}
+
+func TestHandlerMetricsDelay(t *testing.T) {
+	handler := api.Handler{
+		Metrics:      textHandler("metrics"),
+		MetricsDelay: 50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	response := doMetricsRequest(&handler)
+
+	if elapsed := time.Since(start); elapsed < handler.MetricsDelay {
+		t.Fatalf("response not delayed: %v", elapsed)
+	}
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "metrics")
+}
+
+func TestHandlerMetricsCSV(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+	}, []string{"code"})
+
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "duration_seconds",
+		Buckets: []float64{0.5, 1},
+	})
+
+	registry.MustRegister(requests, duration)
+
+	requests.WithLabelValues("200").Add(3)
+	requests.WithLabelValues("500").Inc()
+	duration.Observe(0.25)
+	duration.Observe(0.75)
+
+	response := doRequest(&api.Handler{Gatherer: registry}, http.MethodGet, "/-/metrics.csv")
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkHeader(t, response, "Content-Type", "text/csv")
+	checkBody(t, response, strings.Join([]string{
+		"name,labels,value",
+		`duration_seconds_bucket,"le=""0.5""",1`,
+		`duration_seconds_bucket,"le=""1""",2`,
+		`duration_seconds_bucket,"le=""+Inf""",2`,
+		"duration_seconds_sum,,1",
+		"duration_seconds_count,,2",
+		`requests_total,"code=""200""",3`,
+		`requests_total,"code=""500""",1`,
+	}, "\n")+"\n")
+}
+
+func TestHandlerMetricsCSVEmpty(t *testing.T) {
+	response := doRequest(&api.Handler{Gatherer: prometheus.NewRegistry()}, http.MethodGet, "/-/metrics.csv")
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "name,labels,value\n")
+}
+
+func TestHandlerMetricsCSVDisabled(t *testing.T) {
+	response := doRequest(&api.Handler{}, http.MethodGet, "/-/metrics.csv")
+
+	checkStatusCode(t, response, http.StatusNotFound)
+}
+
+func TestHandlerLogRequests(t *testing.T) {
+	var output bytes.Buffer
+
+	handler := api.Handler{
+		Metrics: textHandler("metrics"),
+		Logger:  slog.New(slog.NewTextHandler(&output, nil)),
+	}
+
+	checkStatusCode(t, doMetricsRequest(&handler), http.StatusOK)
+	checkStatusCode(t, doRequest(&handler, http.MethodGet, "/missing"), http.StatusNotFound)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("invalid number of log lines: %d", len(lines))
+	}
+
+	for i, want := range []string{
+		"method=GET path=/metrics status=200 duration=",
+		"method=GET path=/missing status=404 duration=",
+	} {
+		if !strings.Contains(lines[i], want) {
+			t.Fatalf("invalid log line %q, missing %q", lines[i], want)
+		}
+	}
+}
+
+func TestHandlerSetMetrics(t *testing.T) {
+	handler := api.Handler{
+		Metrics: textHandler("old"),
+	}
+
+	handler.SetMetrics(textHandler("new"))
+
+	response := doMetricsRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "new")
+}
+
+func TestHandlerCustomMetricsPath(t *testing.T) {
+	handler := api.Handler{
+		Metrics:     textHandler("metrics"),
+		MetricsPath: "/internal/abcd/metrics",
+	}
+
+	response := doRequest(&handler, http.MethodGet, "/internal/abcd/metrics")
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "metrics")
+
+	response = doMetricsRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusNotFound)
+}
+
+func TestHandlerFavicon(t *testing.T) {
+	handler := api.Handler{}
+
+	response := doRequest(&handler, http.MethodGet, "/favicon.ico")
+
+	checkStatusCode(t, response, http.StatusNoContent)
+}
+
+func TestHandlerFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("addr", ":8080", "")
+	flags.Int("errors-percentage", 10, "")
+	flags.String("auth-pass", "", "")
+	flags.String("token", "", "")
+
+	if err := flags.Parse([]string{"-errors-percentage", "20", "-auth-pass", "secret", "-token", "abcd"}); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+
+	handler := api.Handler{
+		Flags: flags,
+	}
+
+	response := doFlagsRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, `{"addr":":8080","auth-pass":"[REDACTED]","errors-percentage":"20","token":"[REDACTED]"}`+"\n")
+}
+
+func TestHandlerFlagsDisabled(t *testing.T) {
+	handler := api.Handler{}
+
+	response := doFlagsRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusNotFound)
+}
+
+func TestHandlerShutdownDisabled(t *testing.T) {
+	handler := api.Handler{}
+
+	response := doShutdownRequest(&handler, "user", "pass")
+
+	checkStatusCode(t, response, http.StatusNotFound)
+}
+
+func TestHandlerShutdownUnauthorized(t *testing.T) {
+	var shutdown bool
+
+	handler := api.Handler{
+		Shutdown: func() { shutdown = true },
+		AuthUser: "user",
+		AuthPass: "pass",
+	}
+
+	response := doShutdownRequest(&handler, "user", "wrong")
+
+	checkStatusCode(t, response, http.StatusUnauthorized)
+
+	if shutdown {
+		t.Fatalf("shutdown triggered")
+	}
+}
+
+func TestHandlerShutdown(t *testing.T) {
+	var shutdown bool
+
+	handler := api.Handler{
+		Shutdown: func() { shutdown = true },
+		AuthUser: "user",
+		AuthPass: "pass",
+	}
+
+	response := doShutdownRequest(&handler, "user", "pass")
+
+	checkStatusCode(t, response, http.StatusAccepted)
+
+	if !shutdown {
+		t.Fatalf("shutdown not triggered")
+	}
+}
+
+func TestHandlerConfigAuth(t *testing.T) {
+	handler := api.Handler{
+		Config:   newLimitsConfig(t),
+		Metrics:  textHandler("metrics"),
+		AuthUser: "user",
+		AuthPass: "pass",
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   string
+		pass   string
+		status int
+	}{
+		{"config-authorized", http.MethodGet, "/-/config", "user", "pass", http.StatusOK},
+		{"config-wrong-password", http.MethodGet, "/-/config", "user", "wrong", http.StatusUnauthorized},
+		{"config-missing-credentials", http.MethodGet, "/-/config", "", "", http.StatusUnauthorized},
+		{"errors-percentage-authorized", http.MethodGet, "/-/config/errors-percentage", "user", "pass", http.StatusOK},
+		{"errors-percentage-unauthorized", http.MethodPut, "/-/config/errors-percentage", "", "", http.StatusUnauthorized},
+		{"unclean-path-unauthorized", http.MethodGet, "/-/config/../config/errors-percentage", "", "", http.StatusUnauthorized},
+		{"maintenance-authorized", http.MethodPut, "/-/maintenance", "user", "pass", http.StatusBadRequest},
+		{"maintenance-unauthorized", http.MethodPut, "/-/maintenance", "", "", http.StatusUnauthorized},
+		{"maintenance-status", http.MethodGet, "/-/maintenance", "", "", http.StatusOK},
+		{"health", http.MethodGet, "/-/health", "", "", http.StatusOK},
+		{"live", http.MethodGet, "/-/live", "", "", http.StatusOK},
+		{"metrics", http.MethodGet, "/metrics", "", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.path, nil)
+
+			if test.user != "" || test.pass != "" {
+				request.SetBasicAuth(test.user, test.pass)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			response := recorder.Result()
+
+			checkStatusCode(t, response, test.status)
+
+			if test.status == http.StatusUnauthorized {
+				checkHeader(t, response, "WWW-Authenticate", `Basic realm="metrics-generator"`)
+			}
+		})
+	}
+}
+
+func TestHandlerConfigAuthDisabled(t *testing.T) {
+	response := doGetConfigRequest(handlerForConfig(newLimitsConfig(t)))
+
+	checkStatusCode(t, response, http.StatusOK)
+}
+
+func TestHandlerReady(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	handler := api.Handler{
+		Config:         newLimitsConfig(t),
+		ReadinessGrace: 10 * time.Second,
+		Now: func() time.Time {
+			return now
+		},
+	}
+
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusOK)
+
+	// Changing the errors percentage is not disruptive.
+	checkStatusCode(t, doSetErrorsPercentageRequest(&handler, strings.NewReader("50")), http.StatusOK)
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusOK)
+
+	checkStatusCode(t, doSetDurationIntervalRequest(&handler, strings.NewReader("2,20")), http.StatusOK)
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusServiceUnavailable)
+
+	now = now.Add(9 * time.Second)
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusServiceUnavailable)
+
+	now = now.Add(time.Second)
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusOK)
+}
+
+func TestHandlerReadyInvalidChange(t *testing.T) {
+	handler := api.Handler{
+		Config:         newLimitsConfig(t),
+		ReadinessGrace: time.Hour,
+	}
+
+	checkStatusCode(t, doSetDurationIntervalRequest(&handler, strings.NewReader("20,2")), http.StatusBadRequest)
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusOK)
+}
+
+func TestHandlerReadyReadiness(t *testing.T) {
+	readiness := fakeReadiness(false)
+
+	handler := api.Handler{
+		Readiness: &readiness,
+	}
+
+	response := doReadyRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusServiceUnavailable)
+	checkBody(t, response, "not generating metrics\n")
+
+	readiness = true
+
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusOK)
+}
+
+func TestHandlerLive(t *testing.T) {
+	readiness := fakeReadiness(false)
+
+	handler := api.Handler{
+		Readiness: &readiness,
+	}
+
+	response := doLiveRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+}
+
+func TestHandlerLiveStartupDelay(t *testing.T) {
+	handler := api.Handler{
+		StartupDelay: time.Hour,
+		Started:      time.Now(),
+	}
+
+	checkStatusCode(t, doLiveRequest(&handler), http.StatusOK)
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusServiceUnavailable)
+	checkStatusCode(t, doHealthRequest(&handler), http.StatusServiceUnavailable)
+}
+
+func TestHandlerReadyWithoutGrace(t *testing.T) {
+	handler := handlerForConfig(newLimitsConfig(t))
+
+	checkStatusCode(t, doSetDurationIntervalRequest(handler, strings.NewReader("2,20")), http.StatusOK)
+	checkStatusCode(t, doReadyRequest(handler), http.StatusOK)
+}
+
+func TestHandlerMaintenance(t *testing.T) {
+	handler := api.Handler{
+		Metrics: textHandler("metrics"),
+	}
+
+	response := doGetMaintenanceRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, `{"enabled":false}`+"\n")
+
+	response = doSetMaintenanceRequest(&handler, strings.NewReader(`{"enabled":true}`))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+
+	response = doGetMaintenanceRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, `{"enabled":true}`+"\n")
+
+	checkStatusCode(t, doHealthRequest(&handler), http.StatusServiceUnavailable)
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusServiceUnavailable)
+	checkStatusCode(t, doLiveRequest(&handler), http.StatusOK)
+	checkStatusCode(t, doMetricsRequest(&handler), http.StatusOK)
+
+	response = doSetMaintenanceRequest(&handler, strings.NewReader(`{"enabled":false}`))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkStatusCode(t, doHealthRequest(&handler), http.StatusOK)
+	checkStatusCode(t, doReadyRequest(&handler), http.StatusOK)
+	checkStatusCode(t, doMetricsRequest(&handler), http.StatusOK)
+}
+
+func TestHandlerSetMaintenanceInvalid(t *testing.T) {
+	handler := api.Handler{}
+
+	response := doSetMaintenanceRequest(&handler, strings.NewReader("boom"))
+
+	checkStatusCode(t, response, http.StatusBadRequest)
+}
+
+func TestHandlerGetDurationInterval(t *testing.T) {
+	config := mockConfig{
+		doDurationInterval: func() (float64, float64) {
+			return 12, 34
+		},
+	}
+
+	response := doGetDurationIntervalRequest(handlerForConfig(config))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "12,34\n")
+}
+
+func TestHandlerSetDurationInterval(t *testing.T) {
+	var minDuration, maxDuration float64
+
+	config := mockConfig{
+		doSetDurationInterval: func(min, max float64) error {
+			minDuration = min
+			maxDuration = max
+			return nil
+		},
+	}
+
+	response := doSetDurationIntervalRequest(handlerForConfig(config), strings.NewReader("12,34"))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+	checkFloatEqual(t, "minimum duration", minDuration, 12)
+	checkFloatEqual(t, "maximum duration", maxDuration, 34)
+}
+
+func TestHandlerGetDurationIntervalFractional(t *testing.T) {
+	config := mockConfig{
+		doDurationInterval: func() (float64, float64) {
+			return 0.05, 0.5
+		},
+	}
+
+	response := doGetDurationIntervalRequest(handlerForConfig(config))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "0.05,0.5\n")
+}
+
+func TestHandlerSetDurationIntervalFractional(t *testing.T) {
+	config := newLimitsConfig(t)
+
+	response := doSetDurationIntervalRequest(handlerForConfig(config), strings.NewReader("0.05,0.5"))
+
+	checkStatusCode(t, response, http.StatusOK)
+
+	min, max := config.DurationIntervalSeconds()
+
+	checkFloatEqual(t, "minimum duration", min, 0.05)
+	checkFloatEqual(t, "maximum duration", max, 0.5)
+}
+
+func TestHandlerDurationIntervalIntegerConfig(t *testing.T) {
+	config := durationConfig{min: 1, max: 10}
+	handler := handlerForConfig(&config)
+
+	response := doGetDurationIntervalRequest(handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "1,10\n")
+
+	checkStatusCode(t, doSetDurationIntervalRequest(handler, strings.NewReader("2,20")), http.StatusOK)
+
+	if config.min != 2 || config.max != 20 {
+		t.Fatalf("invalid duration interval: %d,%d", config.min, config.max)
+	}
+
+	checkStatusCode(t, doSetDurationIntervalRequest(handler, strings.NewReader("0.5,20")), http.StatusBadRequest)
+
+	if config.min != 2 || config.max != 20 {
+		t.Fatalf("duration interval changed: %d,%d", config.min, config.max)
+	}
+}
+
+func TestHandlerSetDurationIntervalInvalid(t *testing.T) {
+	handler := api.Handler{
+		Config: mockConfig{},
+	}
+
+	response := doSetDurationIntervalRequest(&handler, strings.NewReader("boom"))
+
+	checkStatusCode(t, response, http.StatusBadRequest)
+	checkHeader(t, response, "Content-Type", "application/json")
+	checkBody(t, response, `{"error":"not a pair of numbers","value":"boom"}`+"\n")
+}
+
+func TestHandlerSetDurationIntervalInvalidField(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		wanted string
+	}{
+		{
+			name:   "invalid-min",
+			body:   "boom,10",
+			wanted: `{"error":"minimum is not a number","value":"boom"}`,
+		},
+		{
+			name:   "invalid-max",
+			body:   "1,boom",
+			wanted: `{"error":"maximum is not a number","value":"boom"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := api.Handler{
+				Config: mockConfig{},
+			}
+
+			response := doSetDurationIntervalRequest(&handler, strings.NewReader(test.body))
+
+			checkStatusCode(t, response, http.StatusBadRequest)
+			checkBody(t, response, test.wanted+"\n")
+		})
+	}
+}
+
+func TestHandlerSetDurationIntervalNotFinite(t *testing.T) {
+	for _, body := range []string{"NaN,NaN", "1,Inf", "-Inf,10"} {
+		config := newLimitsConfig(t)
+
+		response := doSetDurationIntervalRequest(handlerForConfig(config), strings.NewReader(body))
+
+		checkStatusCode(t, response, http.StatusBadRequest)
+
+		if min, max := config.DurationIntervalSeconds(); min != 1 || max != 10 {
+			t.Fatalf("duration interval changed for %q: %v,%v", body, min, max)
+		}
+	}
+}
+
+func TestHandlerSetDurationIntervalReadError(t *testing.T) {
+	handler := api.Handler{
+		Config: mockConfig{},
+	}
+
+	response := doSetDurationIntervalRequest(&handler, iotest.ErrReader(errors.New("error")))
+
+	checkStatusCode(t, response, http.StatusInternalServerError)
+}
+
+func TestHandlerSetDurationIntervalCancelled(t *testing.T) {
+	handler := api.Handler{
+		Config: mockConfig{},
+	}
+
+	response := doCancelledRequest(t, &handler, http.MethodPut, "/-/config/duration-interval")
+
+	checkStatusCode(t, response, http.StatusRequestTimeout)
+}
+
+func TestHandlerSetDurationIntervalConfigError(t *testing.T) {
+	config := mockConfig{
+		doSetDurationInterval: func(min, max float64) error {
+			return errors.New("error")
+		},
+	}
+
+	response := doSetDurationIntervalRequest(handlerForConfig(config), strings.NewReader("12,34"))
+
+	checkStatusCode(t, response, http.StatusBadRequest)
+}
+
+func TestHandlerDurationScale(t *testing.T) {
+	config := newLimitsConfig(t)
+
+	handler := handlerForConfig(config)
+
+	response := doGetDurationScaleRequest(handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "1\n")
+
+	response = doSetDurationScaleRequest(handler, strings.NewReader("2.5"))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+	checkFloatEqual(t, "duration scale", config.DurationScale(), 2.5)
+
+	response = doGetDurationScaleRequest(handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "2.5\n")
+}
+
+func TestHandlerSetDurationScaleInvalid(t *testing.T) {
+	for _, body := range []string{"boom", "0", "-1", "Inf", "NaN"} {
+		config := newLimitsConfig(t)
+
+		response := doSetDurationScaleRequest(handlerForConfig(config), strings.NewReader(body))
+
+		checkStatusCode(t, response, http.StatusBadRequest)
+		checkFloatEqual(t, "duration scale", config.DurationScale(), 1)
+	}
+}
+
+func TestHandlerDurationLambda(t *testing.T) {
+	config := newLimitsConfig(t)
+
+	handler := handlerForConfig(config)
+
+	response := doGetDurationLambdaRequest(handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "1\n")
+
+	response = doSetDurationLambdaRequest(handler, strings.NewReader("0.25"))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+	checkFloatEqual(t, "duration lambda", config.DurationLambda(), 0.25)
+}
+
+func TestHandlerSetDurationLambdaInvalid(t *testing.T) {
+	for _, body := range []string{"boom", "0", "-1"} {
+		config := newLimitsConfig(t)
+
+		response := doSetDurationLambdaRequest(handlerForConfig(config), strings.NewReader(body))
+
+		checkStatusCode(t, response, http.StatusBadRequest)
+		checkFloatEqual(t, "duration lambda", config.DurationLambda(), 1)
+	}
+}
+
+func TestHandlerGetErrorsPercentage(t *testing.T) {
+	config := mockConfig{
+		doVersionedErrorsPercentage: func() (int, uint64) {
+			return 12, 3
+		},
+	}
+
+	response := doGetErrorsPercentageRequest(handlerForConfig(config))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "12\n")
+	checkHeader(t, response, "ETag", `"12-3"`)
+}
+
+// errorsConfig implements only the unversioned errors percentage part of the
+// configuration.
+type errorsConfig struct {
+	value int
+}
+
+func (c *errorsConfig) ErrorsPercentage() int {
+	return c.value
+}
+
+func (c *errorsConfig) SetErrorsPercentage(value int) error {
+	c.value = value
+	return nil
+}
+
+func TestHandlerGetErrorsPercentageUnversioned(t *testing.T) {
+	handler := handlerForConfig(&errorsConfig{value: 12})
+
+	response := doGetErrorsPercentageRequest(handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "12\n")
+	checkHeader(t, response, "ETag", "")
+
+	response = doConditionalGetErrorsPercentageRequest(handler, "*")
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "12\n")
+}
+
+func TestHandlerGetErrorsPercentageNotModified(t *testing.T) {
+	config := newLimitsConfig(t)
+	handler := handlerForConfig(config)
+
+	first := doGetErrorsPercentageRequest(handler)
+
+	checkStatusCode(t, first, http.StatusOK)
+
+	etag := first.Header.Get("ETag")
+
+	response := doConditionalGetErrorsPercentageRequest(handler, etag)
+
+	checkStatusCode(t, response, http.StatusNotModified)
+	checkBody(t, response, "")
+}
+
+func TestHandlerGetErrorsPercentageModified(t *testing.T) {
+	config := newLimitsConfig(t)
+	handler := handlerForConfig(config)
+
+	first := doGetErrorsPercentageRequest(handler)
+
+	checkStatusCode(t, first, http.StatusOK)
+
+	etag := first.Header.Get("ETag")
+
+	if err := config.SetErrorsPercentage(20); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	response := doConditionalGetErrorsPercentageRequest(handler, etag)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "20\n")
+
+	if response.Header.Get("ETag") == etag {
+		t.Fatalf("ETag not changed")
+	}
+}
+
+func TestHandlerSetErrorsPercentage(t *testing.T) {
+	var errorsPercentage int
+
+	config := mockConfig{
+		doSetErrorsPercentage: func(value int) error {
+			errorsPercentage = value
+			return nil
+		},
+	}
+
+	response := doSetErrorsPercentageRequest(handlerForConfig(config), strings.NewReader("12"))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+	checkIntEqual(t, "errors percentage", errorsPercentage, 12)
+}
+
+func TestHandlerSetErrorsPercentageSameValue(t *testing.T) {
+	config := newLimitsConfig(t)
+
+	var changes int
+
+	config.OnChange(func(limits.Settings) {
+		changes++
+	})
+
+	response := doSetErrorsPercentageRequest(handlerForConfig(config), strings.NewReader("10"))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+	checkIntEqual(t, "number of changes", changes, 0)
+}
+
+func TestHandlerSetErrorsPercentageInvalid(t *testing.T) {
+	handler := api.Handler{
+		Config: mockConfig{},
+	}
+
+	response := doSetErrorsPercentageRequest(&handler, strings.NewReader("boom"))
+
+	checkStatusCode(t, response, http.StatusBadRequest)
+}
+
+func TestHandlerSetErrorsPercentageReadError(t *testing.T) {
+	handler := api.Handler{
+		Config: mockConfig{},
+	}
+
+	response := doSetErrorsPercentageRequest(&handler, iotest.ErrReader(errors.New("error")))
+
+	checkStatusCode(t, response, http.StatusInternalServerError)
+}
+
+func TestHandlerSetErrorsPercentageCancelled(t *testing.T) {
+	handler := api.Handler{
+		Config: mockConfig{},
+	}
+
+	response := doCancelledRequest(t, &handler, http.MethodPut, "/-/config/errors-percentage")
+
+	checkStatusCode(t, response, http.StatusRequestTimeout)
+}
+
+func TestHandlerSetErrorsPercentageConfigError(t *testing.T) {
+	config := mockConfig{
+		doSetErrorsPercentage: func(value int) error {
+			return errors.New("error")
+		},
+	}
+
+	response := doSetErrorsPercentageRequest(handlerForConfig(config), strings.NewReader("12"))
+
+	checkStatusCode(t, response, http.StatusBadRequest)
+}
+
+func TestHandlerAdjustErrorsPercentage(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		initial int
+		wanted  int
+	}{
+		{
+			name:    "increment",
+			path:    "/-/config/errors-percentage/increment",
+			body:    `{"step":5}`,
+			initial: 10,
+			wanted:  15,
+		},
+		{
+			name:    "decrement",
+			path:    "/-/config/errors-percentage/decrement",
+			body:    `{"step":5}`,
+			initial: 10,
+			wanted:  5,
+		},
+		{
+			name:    "default-step",
+			path:    "/-/config/errors-percentage/increment",
+			body:    "",
+			initial: 10,
+			wanted:  11,
+		},
+		{
+			name:    "clamp-max",
+			path:    "/-/config/errors-percentage/increment",
+			body:    `{"step":50}`,
+			initial: 80,
+			wanted:  100,
+		},
+		{
+			name:    "clamp-min",
+			path:    "/-/config/errors-percentage/decrement",
+			body:    `{"step":50}`,
+			initial: 20,
+			wanted:  0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := newLimitsConfig(t)
+
+			if err := config.SetErrorsPercentage(test.initial); err != nil {
+				t.Fatalf("set errors percentage: %v", err)
+			}
+
+			response := doRequestWithBody(handlerForConfig(config), http.MethodPost, test.path, strings.NewReader(test.body))
+
+			checkStatusCode(t, response, http.StatusOK)
+			checkBody(t, response, fmt.Sprintf("%d\n", test.wanted))
+			checkIntEqual(t, "errors percentage", config.ErrorsPercentage(), test.wanted)
+		})
+	}
+}
+
+func TestHandlerAdjustErrorsPercentageInvalidStep(t *testing.T) {
+	config := newLimitsConfig(t)
 
-	response := doHealthRequest(&handler)
+	response := doRequestWithBody(handlerForConfig(config), http.MethodPost, "/-/config/errors-percentage/increment", strings.NewReader(`{"step":-5}`))
 
-	checkStatusCode(t, response, http.StatusOK)
-	checkBody(t, response, "OK\n")
+	checkStatusCode(t, response, http.StatusBadRequest)
+	checkIntEqual(t, "errors percentage", config.ErrorsPercentage(), 10)
 }
 
-func TestHandlerGetDurationInterval(t *testing.T) {
+func TestHandlerGetRequestRate(t *testing.T) {
 	config := mockConfig{
-		doDurationInterval: func() (int, int) {
-			return 12, 34
+		doRequestRate: func() int {
+			return 5
 		},
 	}
 
-	response := doGetDurationIntervalRequest(handlerForConfig(config))
+	response := doGetRequestRateRequest(handlerForConfig(config))
 
 	checkStatusCode(t, response, http.StatusOK)
-	checkBody(t, response, "12,34\n")
+	checkBody(t, response, "5\n")
 }
 
-func TestHandlerSetDurationInterval(t *testing.T) {
-	var minDuration, maxDuration int
+func TestHandlerSetRequestRate(t *testing.T) {
+	var requestRate int
 
 	config := mockConfig{
-		doSetDurationInterval: func(min, max int) error {
-			minDuration = min
-			maxDuration = max
+		doSetRequestRate: func(value int) error {
+			requestRate = value
 			return nil
 		},
 	}
 
-	response := doSetDurationIntervalRequest(handlerForConfig(config), strings.NewReader("12,34"))
+	response := doSetRequestRateRequest(handlerForConfig(config), strings.NewReader("5"))
 
 	checkStatusCode(t, response, http.StatusOK)
 	checkBody(t, response, "OK\n")
-	checkIntEqual(t, "minimum duration", minDuration, 12)
-	checkIntEqual(t, "maximum duration", maxDuration, 34)
+	checkIntEqual(t, "request rate", requestRate, 5)
 }
 
-func TestHandlerSetDurationIntervalInvalid(t *testing.T) {
-	handler := api.Handler{}
-
-	response := doSetDurationIntervalRequest(&handler, strings.NewReader("boom"))
+func TestHandlerSetRequestRateInvalid(t *testing.T) {
+	response := doSetRequestRateRequest(handlerForConfig(mockConfig{}), strings.NewReader("boom"))
 
 	checkStatusCode(t, response, http.StatusBadRequest)
 }
 
-func TestHandlerSetDurationIntervalReadError(t *testing.T) {
-	handler := api.Handler{}
-
-	response := doSetDurationIntervalRequest(&handler, iotest.ErrReader(errors.New("error")))
+func TestHandlerSetRequestRateReadError(t *testing.T) {
+	response := doSetRequestRateRequest(handlerForConfig(mockConfig{}), iotest.ErrReader(errors.New("error")))
 
 	checkStatusCode(t, response, http.StatusInternalServerError)
 }
 
-func TestHandlerSetDurationIntervalConfigError(t *testing.T) {
+func TestHandlerSetRequestRateConfigError(t *testing.T) {
 	config := mockConfig{
-		doSetDurationInterval: func(min, max int) error {
+		doSetRequestRate: func(value int) error {
 			return errors.New("error")
 		},
 	}
 
-	response := doSetDurationIntervalRequest(handlerForConfig(config), strings.NewReader("12,34"))
+	response := doSetRequestRateRequest(handlerForConfig(config), strings.NewReader("5"))
 
 	checkStatusCode(t, response, http.StatusBadRequest)
 }
 
-func TestHandlerGetErrorsPercentage(t *testing.T) {
-	config := mockConfig{
-		doErrorsPercentage: func() int {
-			return 12
-		},
+func TestHandlerStatusDistribution(t *testing.T) {
+	handler := handlerForConfig(newLimitsConfig(t))
+
+	response := doGetStatusDistributionRequest(handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "[]\n")
+
+	response = doSetStatusDistributionRequest(handler, strings.NewReader(`[{"code":404,"weight":3},{"code":503,"weight":1}]`))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+
+	response = doGetStatusDistributionRequest(handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, `[{"code":404,"weight":3},{"code":503,"weight":1}]`+"\n")
+}
+
+func TestHandlerSetStatusDistributionInvalid(t *testing.T) {
+	for _, body := range []string{"boom", `[{"code":200,"weight":1}]`, `[{"code":500,"weight":-1}]`} {
+		config := newLimitsConfig(t)
+
+		response := doSetStatusDistributionRequest(handlerForConfig(config), strings.NewReader(body))
+
+		checkStatusCode(t, response, http.StatusBadRequest)
+
+		if got := config.StatusDistribution(); got != nil {
+			t.Fatalf("status distribution changed after invalid value: %v", got)
+		}
 	}
+}
 
-	response := doGetErrorsPercentageRequest(handlerForConfig(config))
+type mockRateHistory []float64
+
+func (h mockRateHistory) Rates() []float64 {
+	return h
+}
+
+func TestHandlerRequestRateHistory(t *testing.T) {
+	handler := api.Handler{
+		Config:      newLimitsConfig(t),
+		RateHistory: mockRateHistory{1, 2.5, 4},
+	}
+
+	response := doGetRequestRateHistoryRequest(&handler)
 
 	checkStatusCode(t, response, http.StatusOK)
-	checkBody(t, response, "12\n")
+	checkHeader(t, response, "Content-Type", "application/json")
+	checkBody(t, response, "[1,2.5,4]\n")
+
+	// The request rate endpoints are still served.
+	response = doGetRequestRateRequest(&handler)
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "1\n")
 }
 
-func TestHandlerSetErrorsPercentage(t *testing.T) {
-	var errorsPercentage int
+func TestHandlerRequestRateHistoryDisabled(t *testing.T) {
+	response := doGetRequestRateHistoryRequest(handlerForConfig(mockConfig{}))
 
-	config := mockConfig{
-		doSetErrorsPercentage: func(value int) error {
-			errorsPercentage = value
-			return nil
+	checkStatusCode(t, response, http.StatusNotFound)
+}
+
+func TestHandlerGetConfig(t *testing.T) {
+	response := doGetConfigRequest(handlerForConfig(newLimitsConfig(t)))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkHeader(t, response, "Content-Type", "application/json")
+	checkBody(t, response, `{"durationMin":1,"durationMax":10,"errorsPercentage":10,"capacity":0,"clientErrorRatio":0,"requestRate":1,"durationScale":1,"durationLambda":1,"statusDistribution":[]}`+"\n")
+}
+
+func TestHandlerGetConfigMetrics(t *testing.T) {
+	response := doRequest(handlerForConfig(newLimitsConfig(t)), http.MethodGet, "/-/config/metrics")
+
+	checkStatusCode(t, response, http.StatusOK)
+
+	var parser expfmt.TextParser
+
+	families, err := parser.TextToMetricFamilies(response.Body)
+	if err != nil {
+		t.Fatalf("parse metrics: %v", err)
+	}
+
+	values := make(map[string]float64)
+
+	for name, family := range families {
+		if family.GetType() != dto.MetricType_GAUGE || len(family.GetMetric()) != 1 {
+			t.Fatalf("invalid metric family %s", name)
+		}
+
+		values[name] = family.GetMetric()[0].GetGauge().GetValue()
+	}
+
+	wanted := map[string]float64{
+		"config_duration_min_seconds": 1,
+		"config_duration_max_seconds": 10,
+		"config_errors_percentage":    10,
+		"config_capacity":             0,
+		"config_client_error_ratio":   0,
+		"config_request_rate":         1,
+		"config_duration_scale":       1,
+		"config_duration_lambda":      1,
+	}
+
+	if diff := cmp.Diff(values, wanted); diff != "" {
+		t.Fatalf("invalid metrics:\n%s", diff)
+	}
+}
+
+func TestHandlerGetConfigStatusDistribution(t *testing.T) {
+	config := newLimitsConfig(t)
+
+	if err := config.SetStatusDistribution(limits.StatusDistribution{{Code: 503, Weight: 1}}); err != nil {
+		t.Fatalf("set status distribution: %v", err)
+	}
+
+	response := doGetConfigRequest(handlerForConfig(config))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, `{"durationMin":1,"durationMax":10,"errorsPercentage":10,"capacity":0,"clientErrorRatio":0,"requestRate":1,"durationScale":1,"durationLambda":1,"statusDistribution":[{"code":503,"weight":1}]}`+"\n")
+}
+
+func TestHandlerGetConfigMetricsStatusDistribution(t *testing.T) {
+	config := newLimitsConfig(t)
+
+	if err := config.SetStatusDistribution(limits.StatusDistribution{{Code: 429, Weight: 2}, {Code: 503, Weight: 1}}); err != nil {
+		t.Fatalf("set status distribution: %v", err)
+	}
+
+	response := doRequest(handlerForConfig(config), http.MethodGet, "/-/config/metrics")
+
+	checkStatusCode(t, response, http.StatusOK)
+
+	var parser expfmt.TextParser
+
+	families, err := parser.TextToMetricFamilies(response.Body)
+	if err != nil {
+		t.Fatalf("parse metrics: %v", err)
+	}
+
+	weights := make(map[string]float64)
+
+	for _, m := range families["config_status_weight"].GetMetric() {
+		weights[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+	}
+
+	if diff := cmp.Diff(weights, map[string]float64{"429": 2, "503": 1}); diff != "" {
+		t.Fatalf("invalid weights:\n%s", diff)
+	}
+}
+
+func TestHandlerSetConfigRoundTrip(t *testing.T) {
+	source := newLimitsConfig(t)
+
+	if err := source.SetErrorsPercentage(50); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	if err := source.SetDurationIntervalSeconds(0.5, 2); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	if err := source.SetStatusDistribution(limits.StatusDistribution{{Code: 429, Weight: 2}, {Code: 503, Weight: 1}}); err != nil {
+		t.Fatalf("set status distribution: %v", err)
+	}
+
+	response := doGetConfigRequest(handlerForConfig(source))
+
+	checkStatusCode(t, response, http.StatusOK)
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	target := newLimitsConfig(t)
+
+	response = doSetConfigRequest(handlerForConfig(target), bytes.NewReader(body))
+
+	checkStatusCode(t, response, http.StatusOK)
+	checkBody(t, response, "OK\n")
+
+	if diff := cmp.Diff(target.Snapshot(), source.Snapshot()); diff != "" {
+		t.Fatalf("invalid configuration:\n%s", diff)
+	}
+}
+
+func TestHandlerSetConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "not-json",
+			body: "boom",
+		},
+		{
+			name: "unknown-field",
+			body: `{"durationMin":1,"durationMax":10,"requestRate":1,"durationScale":1,"boom":1}`,
+		},
+		{
+			name: "invalid-field",
+			body: `{"durationMin":1,"durationMax":10,"errorsPercentage":101,"requestRate":1,"durationScale":1}`,
+		},
+		{
+			name: "missing-field",
+			body: `{"durationMin":1,"durationMax":10,"errorsPercentage":50}`,
 		},
 	}
 
-	response := doSetErrorsPercentageRequest(handlerForConfig(config), strings.NewReader("12"))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := newLimitsConfig(t)
+
+			before := config.Snapshot()
+
+			response := doSetConfigRequest(handlerForConfig(config), strings.NewReader(test.body))
+
+			checkStatusCode(t, response, http.StatusBadRequest)
+
+			if diff := cmp.Diff(config.Snapshot(), before); diff != "" {
+				t.Fatalf("configuration changed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerPatchConfigReplace(t *testing.T) {
+	config := newLimitsConfig(t)
+
+	response := doPatchConfigRequest(handlerForConfig(config), strings.NewReader(`[
+		{"op": "replace", "path": "/errorsPercentage", "value": 50},
+		{"op": "replace", "path": "/durationMax", "value": 20}
+	]`))
 
 	checkStatusCode(t, response, http.StatusOK)
 	checkBody(t, response, "OK\n")
-	checkIntEqual(t, "errors percentage", errorsPercentage, 12)
+	checkIntEqual(t, "errors percentage", config.ErrorsPercentage(), 50)
+	checkFloatEqual(t, "maximum duration", config.Snapshot().MaxDuration, 20)
 }
 
-func TestHandlerSetErrorsPercentageInvalid(t *testing.T) {
-	handler := api.Handler{}
+func TestHandlerPatchConfigStatusDistribution(t *testing.T) {
+	config := newLimitsConfig(t)
 
-	response := doSetErrorsPercentageRequest(&handler, strings.NewReader("boom"))
+	response := doPatchConfigRequest(handlerForConfig(config), strings.NewReader(`[
+		{"op": "test", "path": "/statusDistribution", "value": []},
+		{"op": "replace", "path": "/statusDistribution", "value": [{"code": 503, "weight": 1}]}
+	]`))
 
-	checkStatusCode(t, response, http.StatusBadRequest)
+	checkStatusCode(t, response, http.StatusOK)
+
+	if diff := cmp.Diff(config.StatusDistribution(), limits.StatusDistribution{{Code: 503, Weight: 1}}); diff != "" {
+		t.Fatalf("invalid status distribution:\n%s", diff)
+	}
 }
 
-func TestHandlerSetErrorsPercentageReadError(t *testing.T) {
-	handler := api.Handler{}
+func TestHandlerPatchConfigTestAndReplace(t *testing.T) {
+	config := newLimitsConfig(t)
 
-	response := doSetErrorsPercentageRequest(&handler, iotest.ErrReader(errors.New("error")))
+	response := doPatchConfigRequest(handlerForConfig(config), strings.NewReader(`[
+		{"op": "test", "path": "/errorsPercentage", "value": 10},
+		{"op": "replace", "path": "/errorsPercentage", "value": 50}
+	]`))
 
-	checkStatusCode(t, response, http.StatusInternalServerError)
+	checkStatusCode(t, response, http.StatusOK)
+	checkIntEqual(t, "errors percentage", config.ErrorsPercentage(), 50)
 }
 
-func TestHandlerSetErrorsPercentageConfigError(t *testing.T) {
-	config := mockConfig{
-		doSetErrorsPercentage: func(value int) error {
-			return errors.New("error")
+func TestHandlerPatchConfigTestFailed(t *testing.T) {
+	config := newLimitsConfig(t)
+
+	response := doPatchConfigRequest(handlerForConfig(config), strings.NewReader(`[
+		{"op": "replace", "path": "/durationMax", "value": 20},
+		{"op": "test", "path": "/errorsPercentage", "value": 20},
+		{"op": "replace", "path": "/errorsPercentage", "value": 50}
+	]`))
+
+	checkStatusCode(t, response, http.StatusConflict)
+	checkIntEqual(t, "errors percentage", config.ErrorsPercentage(), 10)
+	checkFloatEqual(t, "maximum duration", config.Snapshot().MaxDuration, 10)
+}
+
+func TestHandlerPatchConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "not-json",
+			body: "boom",
+		},
+		{
+			name: "unsupported-operation",
+			body: `[{"op": "remove", "path": "/errorsPercentage"}]`,
+		},
+		{
+			name: "unknown-path",
+			body: `[{"op": "replace", "path": "/boom", "value": 1}]`,
+		},
+		{
+			name: "invalid-type",
+			body: `[{"op": "replace", "path": "/errorsPercentage", "value": "boom"}]`,
+		},
+		{
+			name: "invalid-value",
+			body: `[{"op": "replace", "path": "/errorsPercentage", "value": 101}]`,
 		},
 	}
 
-	response := doSetErrorsPercentageRequest(handlerForConfig(config), strings.NewReader("12"))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := newLimitsConfig(t)
 
-	checkStatusCode(t, response, http.StatusBadRequest)
+			response := doPatchConfigRequest(handlerForConfig(config), strings.NewReader(test.body))
+
+			checkStatusCode(t, response, http.StatusBadRequest)
+			checkIntEqual(t, "errors percentage", config.ErrorsPercentage(), 10)
+		})
+	}
+}
+
+func newLimitsConfig(t *testing.T) *limits.Config {
+	t.Helper()
+
+	var config limits.Config
+
+	if err := config.SetDurationInterval(1, 10); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	if err := config.SetErrorsPercentage(10); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	if err := config.SetRequestRate(1); err != nil {
+		t.Fatalf("set request rate: %v", err)
+	}
+
+	if err := config.SetDurationScale(1); err != nil {
+		t.Fatalf("set duration scale: %v", err)
+	}
+
+	if err := config.SetDurationLambda(1); err != nil {
+		t.Fatalf("set duration lambda: %v", err)
+	}
+
+	return &config
 }
 
 func handlerForConfig(config api.Config) http.Handler {
@@ -169,6 +1406,18 @@ func handlerForConfig(config api.Config) http.Handler {
 	}
 }
 
+func doGetConfigRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/config")
+}
+
+func doSetConfigRequest(handler http.Handler, body io.Reader) *http.Response {
+	return doRequestWithBody(handler, http.MethodPut, "/-/config", body)
+}
+
+func doPatchConfigRequest(handler http.Handler, body io.Reader) *http.Response {
+	return doRequestWithBody(handler, http.MethodPatch, "/-/config", body)
+}
+
 func doGetDurationIntervalRequest(handler http.Handler) *http.Response {
 	return doRequest(handler, http.MethodGet, "/-/config/duration-interval")
 }
@@ -177,18 +1426,102 @@ func doSetDurationIntervalRequest(handler http.Handler, body io.Reader) *http.Re
 	return doRequestWithBody(handler, http.MethodPut, "/-/config/duration-interval", body)
 }
 
+func doGetDurationScaleRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/config/duration-scale")
+}
+
+func doSetDurationScaleRequest(handler http.Handler, body io.Reader) *http.Response {
+	return doRequestWithBody(handler, http.MethodPut, "/-/config/duration-scale", body)
+}
+
+func doGetDurationLambdaRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/config/duration-lambda")
+}
+
+func doSetDurationLambdaRequest(handler http.Handler, body io.Reader) *http.Response {
+	return doRequestWithBody(handler, http.MethodPut, "/-/config/duration-lambda", body)
+}
+
 func doGetErrorsPercentageRequest(handler http.Handler) *http.Response {
 	return doRequest(handler, http.MethodGet, "/-/config/errors-percentage")
 }
 
+func doConditionalGetErrorsPercentageRequest(handler http.Handler, etag string) *http.Response {
+	request := httptest.NewRequest(http.MethodGet, "/-/config/errors-percentage", nil)
+	request.Header.Set("If-None-Match", etag)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
 func doSetErrorsPercentageRequest(handler http.Handler, body io.Reader) *http.Response {
 	return doRequestWithBody(handler, http.MethodPut, "/-/config/errors-percentage", body)
 }
 
+func doGetRequestRateRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/config/request-rate")
+}
+
+func doSetRequestRateRequest(handler http.Handler, body io.Reader) *http.Response {
+	return doRequestWithBody(handler, http.MethodPut, "/-/config/request-rate", body)
+}
+
+func doGetRequestRateHistoryRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/config/request-rate/history")
+}
+
+func doGetStatusDistributionRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/config/status-distribution")
+}
+
+func doSetStatusDistributionRequest(handler http.Handler, body io.Reader) *http.Response {
+	return doRequestWithBody(handler, http.MethodPut, "/-/config/status-distribution", body)
+}
+
+func textHandler(text string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, text)
+	})
+}
+
+func doMetricsRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/metrics")
+}
+
+func doGetMaintenanceRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/maintenance")
+}
+
+func doSetMaintenanceRequest(handler http.Handler, body io.Reader) *http.Response {
+	return doRequestWithBody(handler, http.MethodPut, "/-/maintenance", body)
+}
+
+func doFlagsRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/flags")
+}
+
+func doShutdownRequest(handler http.Handler, user, pass string) *http.Response {
+	request := httptest.NewRequest(http.MethodPost, "/-/shutdown", nil)
+	request.SetBasicAuth(user, pass)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
+func doReadyRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/ready")
+}
+
 func doHealthRequest(handler http.Handler) *http.Response {
 	return doRequest(handler, http.MethodGet, "/-/health")
 }
 
+func doLiveRequest(handler http.Handler) *http.Response {
+	return doRequest(handler, http.MethodGet, "/-/live")
+}
+
 func doRequest(handler http.Handler, method string, path string) *http.Response {
 	recorder := httptest.NewRecorder()
 	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
@@ -201,6 +1534,22 @@ func doRequestWithBody(handler http.Handler, method string, path string, body io
 	return recorder.Result()
 }
 
+// doCancelledRequest sends a request whose body blocks forever, with a context
+// that is already cancelled.
+func doCancelledRequest(t *testing.T, handler http.Handler, method string, path string) *http.Response {
+	t.Helper()
+
+	body, writer := io.Pipe()
+	defer writer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, body).WithContext(ctx))
+	return recorder.Result()
+}
+
 func checkStatusCode(t *testing.T, response *http.Response, wanted int) {
 	t.Helper()
 
@@ -209,6 +1558,14 @@ func checkStatusCode(t *testing.T, response *http.Response, wanted int) {
 	}
 }
 
+func checkHeader(t *testing.T, response *http.Response, name string, wanted string) {
+	t.Helper()
+
+	if got := response.Header.Get(name); got != wanted {
+		t.Fatalf("invalid header %s: wanted %q, got %q", name, wanted, got)
+	}
+}
+
 func checkBody(t *testing.T, response *http.Response, wanted string) {
 	t.Helper()
 
@@ -222,6 +1579,14 @@ func checkBody(t *testing.T, response *http.Response, wanted string) {
 	}
 }
 
+func checkFloatEqual(t *testing.T, name string, got, wanted float64) {
+	t.Helper()
+
+	if got != wanted {
+		t.Fatalf("invalid %s: wanted %v, got %v", name, wanted, got)
+	}
+}
+
 func checkIntEqual(t *testing.T, name string, got, wanted int) {
 	t.Helper()
 
@@ -229,3 +1594,9 @@ func checkIntEqual(t *testing.T, name string, got, wanted int) {
 		t.Fatalf("invalid %s: wanted %d, got %d", name, wanted, got)
 	}
 }
+
+type fakeReadiness bool
+
+func (r *fakeReadiness) Ready() bool {
+	return bool(*r)
+}
@@ -1,31 +1,67 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
 )
 
-func parseDurationInterval(value string) (int, int, error) {
+// valueError is returned when a value in the body of a request can't be
+// parsed. It reports the offending value, so that the response can point the
+// client to it.
+type valueError struct {
+	Message string `json:"error"`
+	Value   string `json:"value"`
+}
+
+func (e valueError) Error() string {
+	return fmt.Sprintf("%s: %q", e.Message, e.Value)
+}
+
+func parseDurationInterval(value string) (float64, float64, error) {
 	parts := strings.Split(value, ",")
 
 	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("not a pair of number")
+		return 0, 0, valueError{Message: "not a pair of numbers", Value: value}
 	}
 
-	min, err := parseInt(parts[0])
+	min, err := parseFloat(parts[0])
 	if err != nil {
-		return 0, 0, fmt.Errorf("minimum is not a number")
+		return 0, 0, valueError{Message: "minimum is not a number", Value: strings.TrimSpace(parts[0])}
 	}
 
-	max, err := parseInt(parts[1])
+	max, err := parseFloat(parts[1])
 	if err != nil {
-		return 0, 0, fmt.Errorf("maximum is not a number")
+		return 0, 0, valueError{Message: "maximum is not a number", Value: strings.TrimSpace(parts[1])}
 	}
 
 	return min, max, nil
 }
 
+func parseFloat(value string) (float64, error) {
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a number")
+	}
+
+	if math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return 0, fmt.Errorf("not a finite number")
+	}
+
+	return parsed, nil
+}
+
+// formatFloat formats a number without trailing zeros, so that whole numbers
+// don't have a decimal part.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
 func parseInt(value string) (int, error) {
 	parsed, err := strconv.Atoi(strings.TrimSpace(string(value)))
 	if err != nil {
@@ -34,3 +70,62 @@ func parseInt(value string) (int, error) {
 
 	return parsed, nil
 }
+
+// parseSettings parses a JSON object containing every field of the
+// configuration. Unknown fields are rejected, and missing fields are zero.
+func parseSettings(data []byte) (limits.Settings, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var settings limits.Settings
+
+	if err := decoder.Decode(&settings); err != nil {
+		return limits.Settings{}, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	return settings, nil
+}
+
+// defaultStep is the step used to adjust a value when no step is specified.
+const defaultStep = 1
+
+type stepRequest struct {
+	Step *int `json:"step"`
+}
+
+// parseStep parses a JSON object in the form {"step":n}, where n is a number
+// greater than zero. If data is empty or the step is missing, defaultStep is
+// returned.
+func parseStep(data []byte) (int, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return defaultStep, nil
+	}
+
+	var request stepRequest
+
+	if err := json.Unmarshal(data, &request); err != nil {
+		return 0, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	if request.Step == nil {
+		return defaultStep, nil
+	}
+
+	if *request.Step <= 0 {
+		return 0, fmt.Errorf("step is less than or equal to zero")
+	}
+
+	return *request.Step, nil
+}
+
+func clampPercentage(value int) int {
+	if value < 0 {
+		return 0
+	}
+
+	if value > 100 {
+		return 100
+	}
+
+	return value
+}
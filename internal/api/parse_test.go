@@ -14,38 +14,111 @@ func TestParseDurationInterval(t *testing.T) {
 	}
 }
 
+func TestParseDurationIntervalFractional(t *testing.T) {
+	if min, max, err := parseDurationInterval("0.05, 0.5"); err != nil {
+		t.Fatalf("error: %v", err)
+	} else if min != 0.05 {
+		t.Fatalf("invalid minimum duration: %v", min)
+	} else if max != 0.5 {
+		t.Fatalf("invalid maximum duration: %v", max)
+	}
+}
+
 func TestParseDurationIntervalError(t *testing.T) {
 	tests := []struct {
-		name  string
-		value string
+		name   string
+		value  string
+		wanted valueError
 	}{
 		{
-			name:  "empty",
-			value: "",
+			name:   "empty",
+			value:  "",
+			wanted: valueError{Message: "not a pair of numbers", Value: ""},
 		},
 		{
-			name:  "one-value",
-			value: "12",
+			name:   "one-value",
+			value:  "12",
+			wanted: valueError{Message: "not a pair of numbers", Value: "12"},
 		},
 		{
-			name:  "three-values",
-			value: "12,34,56",
+			name:   "three-values",
+			value:  "12,34,56",
+			wanted: valueError{Message: "not a pair of numbers", Value: "12,34,56"},
 		},
 		{
-			name:  "invalid-min",
-			value: "boom,34",
+			name:   "invalid-min",
+			value:  "boom,34",
+			wanted: valueError{Message: "minimum is not a number", Value: "boom"},
 		},
 		{
-			name:  "invalid-max",
-			value: "12,boom",
+			name:   "invalid-max",
+			value:  "12, boom",
+			wanted: valueError{Message: "maximum is not a number", Value: "boom"},
+		},
+		{
+			name:   "nan-min",
+			value:  "NaN,34",
+			wanted: valueError{Message: "minimum is not a number", Value: "NaN"},
+		},
+		{
+			name:   "inf-max",
+			value:  "12,Inf",
+			wanted: valueError{Message: "maximum is not a number", Value: "Inf"},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			if _, _, err := parseDurationInterval(test.value); err == nil {
+			_, _, err := parseDurationInterval(test.value)
+			if err == nil {
 				t.Fatalf("no error returned")
 			}
+
+			if err != test.wanted {
+				t.Fatalf("invalid error: wanted %v, got %v", test.wanted, err)
+			}
+		})
+	}
+}
+
+func TestParseStep(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		step  int
+	}{
+		{
+			name:  "empty",
+			value: "",
+			step:  1,
+		},
+		{
+			name:  "missing-step",
+			value: "{}",
+			step:  1,
+		},
+		{
+			name:  "step",
+			value: `{"step":5}`,
+			step:  5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if step, err := parseStep([]byte(test.value)); err != nil {
+				t.Fatalf("error: %v", err)
+			} else if step != test.step {
+				t.Fatalf("invalid step: %v", step)
+			}
 		})
 	}
 }
+
+func TestParseStepError(t *testing.T) {
+	for _, value := range []string{"boom", `{"step":0}`, `{"step":-1}`, `{"step":"5"}`} {
+		if _, err := parseStep([]byte(value)); err == nil {
+			t.Fatalf("no error returned for %q", value)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+)
+
+// patchOperation is an operation of a JSON Patch document, as defined in RFC
+// 6902. Only the "replace" and "test" operations are supported.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// errPatchTestFailed is returned when a "test" operation fails.
+type errPatchTestFailed struct {
+	path string
+}
+
+func (e errPatchTestFailed) Error() string {
+	return fmt.Sprintf("test failed for path %q", e.path)
+}
+
+func parsePatch(data []byte) ([]patchOperation, error) {
+	var operations []patchOperation
+
+	if err := json.Unmarshal(data, &operations); err != nil {
+		return nil, fmt.Errorf("invalid JSON Patch document: %v", err)
+	}
+
+	return operations, nil
+}
+
+// applyPatch applies the operations to the settings, in order, and returns the
+// patched settings.
+func applyPatch(settings limits.Settings, operations []patchOperation) (limits.Settings, error) {
+	document, err := settingsDocument(settings)
+	if err != nil {
+		return limits.Settings{}, err
+	}
+
+	for i, operation := range operations {
+		if err := applyPatchOperation(document, operation); err != nil {
+			return limits.Settings{}, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return documentSettings(document)
+}
+
+func applyPatchOperation(document map[string]interface{}, operation patchOperation) error {
+	key, err := patchKey(operation.Path)
+	if err != nil {
+		return err
+	}
+
+	current, ok := document[key]
+	if !ok {
+		return fmt.Errorf("path %q not found", operation.Path)
+	}
+
+	if operation.Value == nil {
+		return fmt.Errorf("missing value")
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(operation.Value, &value); err != nil {
+		return fmt.Errorf("invalid value: %v", err)
+	}
+
+	switch operation.Op {
+	case "replace":
+		document[key] = value
+	case "test":
+		if !reflect.DeepEqual(current, value) {
+			return errPatchTestFailed{path: operation.Path}
+		}
+	default:
+		return fmt.Errorf("unsupported operation %q", operation.Op)
+	}
+
+	return nil
+}
+
+func patchKey(path string) (string, error) {
+	if !strings.HasPrefix(path, "/") || strings.Count(path, "/") != 1 {
+		return "", fmt.Errorf("invalid path %q", path)
+	}
+
+	return strings.TrimPrefix(path, "/"), nil
+}
+
+func settingsDocument(settings limits.Settings) (map[string]interface{}, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("marshal settings: %v", err)
+	}
+
+	var document map[string]interface{}
+
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("unmarshal settings: %v", err)
+	}
+
+	return document, nil
+}
+
+func documentSettings(document map[string]interface{}) (limits.Settings, error) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return limits.Settings{}, fmt.Errorf("marshal document: %v", err)
+	}
+
+	var settings limits.Settings
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return limits.Settings{}, fmt.Errorf("invalid value: %v", err)
+	}
+
+	return settings, nil
+}
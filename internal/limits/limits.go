@@ -1,25 +1,447 @@
 package limits
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"sync"
 )
 
 type Config struct {
 	mu               sync.RWMutex
-	minDuration      int
-	maxDuration      int
+	minDuration      float64
+	maxDuration      float64
 	errorsPercentage int
+	capacity         int
+	clientErrorRatio float64
+	requestRate      int
+	durationScale    float64
+	durationLambda   float64
+
+	// statusDistribution is replaced, never modified, by
+	// SetStatusDistribution and Update, so it can be shared with the callers
+	// of StatusDistribution and Snapshot.
+	statusDistribution StatusDistribution
+
+	// errorsPercentageVersion is incremented every time the errors percentage
+	// changes.
+	errorsPercentageVersion uint64
+
+	// statusDistributionVersion is incremented every time the status
+	// distribution changes.
+	statusDistributionVersion uint64
+
+	// callbacks are registered via OnChange.
+	callbacks []func(Settings)
+}
+
+// Settings is a snapshot of the values of a Config.
+type Settings struct {
+	MinDuration      float64 `json:"durationMin"`
+	MaxDuration      float64 `json:"durationMax"`
+	ErrorsPercentage int     `json:"errorsPercentage"`
+	Capacity         int     `json:"capacity"`
+	ClientErrorRatio float64 `json:"clientErrorRatio"`
+	RequestRate      int     `json:"requestRate"`
+	DurationScale    float64 `json:"durationScale"`
+	DurationLambda   float64 `json:"durationLambda"`
+
+	// StatusDistribution is shared with the Config, and must not be modified.
+	StatusDistribution StatusDistribution `json:"statusDistribution"`
+
+	// StatusDistributionVersion changes every time the status distribution
+	// changes, so that users of the distribution can cache what they derive
+	// from it. It is ignored by Update.
+	StatusDistributionVersion uint64 `json:"-"`
+}
+
+// StatusWeight is the weight of an HTTP status code in a StatusDistribution.
+type StatusWeight struct {
+	Code   int `json:"code"`
+	Weight int `json:"weight"`
+}
+
+// StatusDistribution describes how the failed requests are split across HTTP
+// status codes. Every failed request is assigned a status code with a
+// probability proportional to the weight of the code.
+type StatusDistribution []StatusWeight
+
+// MarshalJSON encodes an empty distribution as an empty array instead of null.
+func (d StatusDistribution) MarshalJSON() ([]byte, error) {
+	if d == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]StatusWeight(d))
+}
+
+// equal returns true if the distributions have the same status codes with the
+// same weights, in the same order.
+func (d StatusDistribution) equal(other StatusDistribution) bool {
+	if len(d) != len(other) {
+		return false
+	}
+
+	for i := range d {
+		if d[i] != other[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TotalWeight returns the sum of the weights of the status codes.
+func (d StatusDistribution) TotalWeight() int {
+	var total int
+
+	for _, w := range d {
+		total += w.Weight
+	}
+
+	return total
 }
 
+// DurationInterval returns the duration interval in whole seconds. Fractions of
+// a second are truncated.
 func (c *Config) DurationInterval() (int, int) {
+	min, max := c.DurationIntervalSeconds()
+	return int(min), int(max)
+}
+
+func (c *Config) SetDurationInterval(minDuration, maxDuration int) error {
+	return c.SetDurationIntervalSeconds(float64(minDuration), float64(maxDuration))
+}
+
+// DurationIntervalSeconds returns the duration interval in seconds, including
+// fractions of a second.
+func (c *Config) DurationIntervalSeconds() (float64, float64) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	return c.minDuration, c.maxDuration
 }
 
-func (c *Config) SetDurationInterval(minDuration, maxDuration int) error {
+func (c *Config) SetDurationIntervalSeconds(minDuration, maxDuration float64) error {
+	if err := validateDurationInterval(minDuration, maxDuration); err != nil {
+		return err
+	}
+
+	return c.set(func() error {
+		c.minDuration = minDuration
+		c.maxDuration = maxDuration
+		return nil
+	})
+}
+
+func (c *Config) ErrorsPercentage() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.errorsPercentage
+}
+
+func (c *Config) SetErrorsPercentage(errorsPercentage int) error {
+	if err := validateErrorsPercentage(errorsPercentage); err != nil {
+		return err
+	}
+
+	return c.set(func() error {
+		if errorsPercentage != c.errorsPercentage {
+			c.errorsPercentageVersion++
+		}
+
+		c.errorsPercentage = errorsPercentage
+		return nil
+	})
+}
+
+// VersionedErrorsPercentage returns the errors percentage together with a
+// version number that changes every time the errors percentage changes.
+func (c *Config) VersionedErrorsPercentage() (int, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.errorsPercentage, c.errorsPercentageVersion
+}
+
+func (c *Config) Capacity() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.capacity
+}
+
+func (c *Config) SetCapacity(capacity int) error {
+	if err := validateCapacity(capacity); err != nil {
+		return err
+	}
+
+	return c.set(func() error {
+		c.capacity = capacity
+		return nil
+	})
+}
+
+func (c *Config) ClientErrorRatio() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.clientErrorRatio
+}
+
+func (c *Config) SetClientErrorRatio(clientErrorRatio float64) error {
+	if err := validateClientErrorRatio(clientErrorRatio); err != nil {
+		return err
+	}
+
+	return c.set(func() error {
+		c.clientErrorRatio = clientErrorRatio
+		return nil
+	})
+}
+
+func (c *Config) RequestRate() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.requestRate
+}
+
+func (c *Config) SetRequestRate(requestRate int) error {
+	if err := validateRequestRate(requestRate); err != nil {
+		return err
+	}
+
+	return c.set(func() error {
+		c.requestRate = requestRate
+		return nil
+	})
+}
+
+// DurationScale returns the factor every simulated duration is multiplied by.
+func (c *Config) DurationScale() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.durationScale
+}
+
+func (c *Config) SetDurationScale(durationScale float64) error {
+	if err := validateDurationScale(durationScale); err != nil {
+		return err
+	}
+
+	return c.set(func() error {
+		c.durationScale = durationScale
+		return nil
+	})
+}
+
+// DurationLambda returns the rate parameter of the exponential distribution of
+// the durations, the inverse of its mean in seconds.
+func (c *Config) DurationLambda() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.durationLambda
+}
+
+func (c *Config) SetDurationLambda(durationLambda float64) error {
+	if err := validateDurationLambda(durationLambda); err != nil {
+		return err
+	}
+
+	return c.set(func() error {
+		c.durationLambda = durationLambda
+		return nil
+	})
+}
+
+// StatusDistribution returns the distribution of the status codes of the
+// failed requests. The returned value must not be modified.
+func (c *Config) StatusDistribution() StatusDistribution {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.statusDistribution
+}
+
+// SetStatusDistribution sets the distribution of the status codes of the failed
+// requests. An empty distribution resets it.
+func (c *Config) SetStatusDistribution(statusDistribution StatusDistribution) error {
+	if err := validateStatusDistribution(statusDistribution); err != nil {
+		return err
+	}
+
+	return c.set(func() error {
+		c.setStatusDistribution(statusDistribution)
+		return nil
+	})
+}
+
+// setStatusDistribution replaces the status distribution with a copy of the
+// given one, unless they are equal.
+func (c *Config) setStatusDistribution(statusDistribution StatusDistribution) {
+	if c.statusDistribution.equal(statusDistribution) {
+		return
+	}
+
+	c.statusDistributionVersion++
+
+	if len(statusDistribution) == 0 {
+		c.statusDistribution = nil
+		return
+	}
+
+	c.statusDistribution = append(StatusDistribution(nil), statusDistribution...)
+}
+
+// Snapshot returns the current values of the Config.
+func (c *Config) Snapshot() Settings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings()
+}
+
+// Update atomically replaces the values of the Config with the ones returned
+// by update, which receives the current values of the Config. If update
+// returns an error, or if any of the returned values is invalid, the Config is
+// left unchanged. update is called while holding the lock on the Config, and
+// must not call any method of the Config.
+func (c *Config) Update(update func(Settings) (Settings, error)) error {
+	return c.set(func() error {
+		settings, err := update(c.settings())
+		if err != nil {
+			return err
+		}
+
+		if err := settings.validate(); err != nil {
+			return err
+		}
+
+		if settings.ErrorsPercentage != c.errorsPercentage {
+			c.errorsPercentageVersion++
+		}
+
+		c.minDuration = settings.MinDuration
+		c.maxDuration = settings.MaxDuration
+		c.errorsPercentage = settings.ErrorsPercentage
+		c.capacity = settings.Capacity
+		c.clientErrorRatio = settings.ClientErrorRatio
+		c.requestRate = settings.RequestRate
+		c.durationScale = settings.DurationScale
+		c.durationLambda = settings.DurationLambda
+		c.setStatusDistribution(settings.StatusDistribution)
+
+		return nil
+	})
+}
+
+// OnChange registers a callback that is called with the new values of the
+// Config after every successful change. Changes that leave the values of the
+// Config as they were don't call the callbacks. Callbacks are called without
+// holding the lock on the Config, so they can call any method of the Config.
+func (c *Config) OnChange(callback func(Settings)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.callbacks = append(c.callbacks, callback)
+}
+
+// set calls change while holding the lock on the Config. If change succeeds and
+// modifies the values of the Config, set calls the callbacks registered via
+// OnChange after releasing the lock.
+func (c *Config) set(change func() error) error {
+	c.mu.Lock()
+
+	before := c.settings()
+
+	if err := change(); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+
+	settings := c.settings()
+
+	if settings.equal(before) {
+		c.mu.Unlock()
+		return nil
+	}
+
+	callbacks := c.callbacks
+
+	c.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(settings)
+	}
+
+	return nil
+}
+
+func (c *Config) settings() Settings {
+	return Settings{
+		MinDuration:      c.minDuration,
+		MaxDuration:      c.maxDuration,
+		ErrorsPercentage: c.errorsPercentage,
+		Capacity:         c.capacity,
+		ClientErrorRatio: c.clientErrorRatio,
+		RequestRate:      c.requestRate,
+		DurationScale:    c.durationScale,
+		DurationLambda:   c.durationLambda,
+
+		StatusDistribution:        c.statusDistribution,
+		StatusDistributionVersion: c.statusDistributionVersion,
+	}
+}
+
+func (s Settings) equal(other Settings) bool {
+	return s.MinDuration == other.MinDuration &&
+		s.MaxDuration == other.MaxDuration &&
+		s.ErrorsPercentage == other.ErrorsPercentage &&
+		s.Capacity == other.Capacity &&
+		s.ClientErrorRatio == other.ClientErrorRatio &&
+		s.RequestRate == other.RequestRate &&
+		s.DurationScale == other.DurationScale &&
+		s.DurationLambda == other.DurationLambda &&
+		s.StatusDistribution.equal(other.StatusDistribution)
+}
+
+func (s Settings) validate() error {
+	if err := validateDurationInterval(s.MinDuration, s.MaxDuration); err != nil {
+		return fmt.Errorf("duration interval: %v", err)
+	}
+	if err := validateErrorsPercentage(s.ErrorsPercentage); err != nil {
+		return fmt.Errorf("errors percentage: %v", err)
+	}
+	if err := validateCapacity(s.Capacity); err != nil {
+		return fmt.Errorf("capacity: %v", err)
+	}
+	if err := validateClientErrorRatio(s.ClientErrorRatio); err != nil {
+		return fmt.Errorf("client error ratio: %v", err)
+	}
+	if err := validateRequestRate(s.RequestRate); err != nil {
+		return fmt.Errorf("request rate: %v", err)
+	}
+	if err := validateDurationScale(s.DurationScale); err != nil {
+		return fmt.Errorf("duration scale: %v", err)
+	}
+	if err := validateDurationLambda(s.DurationLambda); err != nil {
+		return fmt.Errorf("duration lambda: %v", err)
+	}
+	if err := validateStatusDistribution(s.StatusDistribution); err != nil {
+		return fmt.Errorf("status distribution: %v", err)
+	}
+
+	return nil
+}
+
+func validateDurationInterval(minDuration, maxDuration float64) error {
+	if !isFinite(minDuration) || !isFinite(maxDuration) {
+		return fmt.Errorf("duration is not a finite number")
+	}
 	if minDuration <= 0 {
 		return fmt.Errorf("minimum duration is less than or equal to zero")
 	}
@@ -30,28 +452,87 @@ func (c *Config) SetDurationInterval(minDuration, maxDuration int) error {
 		return fmt.Errorf("maximum duration is less then or equal to minimum duration")
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.minDuration = minDuration
-	c.maxDuration = maxDuration
-
 	return nil
 }
 
-func (c *Config) ErrorsPercentage() int {
-	return c.errorsPercentage
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
 }
 
-func (c *Config) SetErrorsPercentage(errorsPercentage int) error {
+func validateErrorsPercentage(errorsPercentage int) error {
 	if errorsPercentage < 0 || errorsPercentage > 100 {
 		return fmt.Errorf("value is not a valid percentage")
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return nil
+}
+
+func validateCapacity(capacity int) error {
+	if capacity < 0 {
+		return fmt.Errorf("capacity is less than zero")
+	}
+
+	return nil
+}
+
+func validateClientErrorRatio(clientErrorRatio float64) error {
+	if !isFinite(clientErrorRatio) || clientErrorRatio < 0 || clientErrorRatio > 1 {
+		return fmt.Errorf("value is not a valid ratio")
+	}
+
+	return nil
+}
+
+func validateDurationScale(durationScale float64) error {
+	if !isFinite(durationScale) {
+		return fmt.Errorf("duration scale is not a finite number")
+	}
+	if durationScale <= 0 {
+		return fmt.Errorf("duration scale is less than or equal to zero")
+	}
+
+	return nil
+}
+
+func validateDurationLambda(durationLambda float64) error {
+	if !isFinite(durationLambda) {
+		return fmt.Errorf("duration lambda is not a finite number")
+	}
+	if durationLambda <= 0 {
+		return fmt.Errorf("duration lambda is less than or equal to zero")
+	}
 
-	c.errorsPercentage = errorsPercentage
+	return nil
+}
+
+func validateStatusDistribution(statusDistribution StatusDistribution) error {
+	seen := make(map[int]bool)
+
+	for _, w := range statusDistribution {
+		if w.Code < 400 || w.Code > 599 {
+			return fmt.Errorf("status code %d is not an error status code", w.Code)
+		}
+		if w.Weight < 0 {
+			return fmt.Errorf("weight of status code %d is less than zero", w.Code)
+		}
+		if seen[w.Code] {
+			return fmt.Errorf("duplicate status code %d", w.Code)
+		}
+
+		seen[w.Code] = true
+	}
+
+	if len(statusDistribution) > 0 && statusDistribution.TotalWeight() == 0 {
+		return fmt.Errorf("all the weights are zero")
+	}
+
+	return nil
+}
+
+func validateRequestRate(requestRate int) error {
+	if requestRate <= 0 {
+		return fmt.Errorf("request rate is less than or equal to zero")
+	}
 
 	return nil
 }
@@ -0,0 +1,538 @@
+package limits
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigUpdate(t *testing.T) {
+	var config Config
+
+	wanted := Settings{
+		MinDuration:      1,
+		MaxDuration:      10,
+		ErrorsPercentage: 20,
+		Capacity:         30,
+		ClientErrorRatio: 0.5,
+		RequestRate:      5,
+		DurationScale:    2,
+		DurationLambda:   0.5,
+
+		StatusDistribution: StatusDistribution{{Code: 503, Weight: 1}},
+	}
+
+	err := config.Update(func(Settings) (Settings, error) {
+		return wanted, nil
+	})
+
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// The version of the status distribution is maintained by the Config.
+	wanted.StatusDistributionVersion = 1
+
+	if diff := cmp.Diff(config.Snapshot(), wanted); diff != "" {
+		t.Fatalf("invalid settings:\n%s", diff)
+	}
+}
+
+func TestConfigUpdateError(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(Settings) (Settings, error)
+	}{
+		{
+			name: "update-error",
+			update: func(s Settings) (Settings, error) {
+				return Settings{}, errors.New("error")
+			},
+		},
+		{
+			name: "invalid-settings",
+			update: func(s Settings) (Settings, error) {
+				s.ErrorsPercentage = 50
+				s.MaxDuration = 0
+				return s, nil
+			},
+		},
+		{
+			name: "invalid-status-distribution",
+			update: func(s Settings) (Settings, error) {
+				s.ErrorsPercentage = 50
+				s.StatusDistribution = StatusDistribution{{Code: 200, Weight: 1}}
+				return s, nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config Config
+
+			if err := config.SetDurationInterval(1, 10); err != nil {
+				t.Fatalf("set duration interval: %v", err)
+			}
+
+			if err := config.SetRequestRate(1); err != nil {
+				t.Fatalf("set request rate: %v", err)
+			}
+
+			if err := config.SetDurationScale(1); err != nil {
+				t.Fatalf("set duration scale: %v", err)
+			}
+
+			before := config.Snapshot()
+
+			if err := config.Update(test.update); err == nil {
+				t.Fatalf("no error returned")
+			}
+
+			if diff := cmp.Diff(config.Snapshot(), before); diff != "" {
+				t.Fatalf("settings changed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigStatusDistributionVersion(t *testing.T) {
+	var config Config
+
+	initial := config.Snapshot().StatusDistributionVersion
+
+	distribution := StatusDistribution{
+		{Code: 404, Weight: 1},
+	}
+
+	if err := config.SetStatusDistribution(distribution); err != nil {
+		t.Fatalf("set status distribution: %v", err)
+	}
+
+	settings := config.Snapshot()
+	version := settings.StatusDistributionVersion
+
+	if diff := cmp.Diff(settings.StatusDistribution, distribution); diff != "" {
+		t.Fatalf("invalid status distribution:\n%s", diff)
+	}
+
+	if version == initial {
+		t.Fatalf("version not changed")
+	}
+
+	// An equal distribution doesn't change the version.
+	if err := config.SetStatusDistribution(StatusDistribution{{Code: 404, Weight: 1}}); err != nil {
+		t.Fatalf("set status distribution: %v", err)
+	}
+
+	if got := config.Snapshot().StatusDistributionVersion; got != version {
+		t.Fatalf("version changed after equal value")
+	}
+
+	if err := config.SetStatusDistribution(StatusDistribution{{Code: 200, Weight: 1}}); err == nil {
+		t.Fatalf("no error returned")
+	}
+
+	if got := config.Snapshot().StatusDistributionVersion; got != version {
+		t.Fatalf("version changed after invalid value")
+	}
+
+	if err := config.SetStatusDistribution(nil); err != nil {
+		t.Fatalf("reset status distribution: %v", err)
+	}
+
+	if got := config.Snapshot().StatusDistributionVersion; got == version {
+		t.Fatalf("version not changed after reset")
+	}
+}
+
+func TestConfigVersionedErrorsPercentage(t *testing.T) {
+	var config Config
+
+	_, initial := config.VersionedErrorsPercentage()
+
+	if err := config.SetErrorsPercentage(20); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	value, version := config.VersionedErrorsPercentage()
+
+	if value != 20 {
+		t.Fatalf("invalid errors percentage: %d", value)
+	}
+
+	if version == initial {
+		t.Fatalf("version not changed")
+	}
+
+	if err := config.SetErrorsPercentage(101); err == nil {
+		t.Fatalf("no error returned")
+	}
+
+	if _, got := config.VersionedErrorsPercentage(); got != version {
+		t.Fatalf("version changed after invalid value")
+	}
+}
+
+// TestConfigConcurrentErrorsPercentage reads and writes the errors percentage
+// concurrently. Run it with -race to detect unsynchronized accesses.
+func TestConfigConcurrentErrorsPercentage(t *testing.T) {
+	var (
+		config Config
+		wg     sync.WaitGroup
+	)
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 1000; i++ {
+			if err := config.SetErrorsPercentage(i % 101); err != nil {
+				t.Errorf("set errors percentage: %v", err)
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 1000; i++ {
+			if value := config.ErrorsPercentage(); value < 0 || value > 100 {
+				t.Errorf("invalid errors percentage: %d", value)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestConfigSetRequestRate(t *testing.T) {
+	var config Config
+
+	if err := config.SetRequestRate(5); err != nil {
+		t.Fatalf("set request rate: %v", err)
+	}
+
+	if got := config.RequestRate(); got != 5 {
+		t.Fatalf("invalid request rate: %d", got)
+	}
+
+	for _, rate := range []int{0, -1} {
+		if err := config.SetRequestRate(rate); err == nil {
+			t.Fatalf("no error returned for %d", rate)
+		}
+	}
+
+	if got := config.RequestRate(); got != 5 {
+		t.Fatalf("request rate changed after invalid value: %d", got)
+	}
+}
+
+func TestConfigSetDurationLambda(t *testing.T) {
+	var config Config
+
+	if err := config.SetDurationLambda(0.5); err != nil {
+		t.Fatalf("set duration lambda: %v", err)
+	}
+
+	for _, lambda := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if err := config.SetDurationLambda(lambda); err == nil {
+			t.Fatalf("no error returned for %v", lambda)
+		}
+	}
+
+	if got := config.DurationLambda(); got != 0.5 {
+		t.Fatalf("invalid duration lambda: %v", got)
+	}
+}
+
+func TestConfigSetClientErrorRatio(t *testing.T) {
+	var config Config
+
+	if err := config.SetClientErrorRatio(0.25); err != nil {
+		t.Fatalf("set client error ratio: %v", err)
+	}
+
+	for _, ratio := range []float64{-0.1, 1.1, math.NaN(), math.Inf(1)} {
+		if err := config.SetClientErrorRatio(ratio); err == nil {
+			t.Fatalf("no error returned for %v", ratio)
+		}
+	}
+
+	if got := config.ClientErrorRatio(); got != 0.25 {
+		t.Fatalf("invalid client error ratio: %v", got)
+	}
+}
+
+func TestConfigSetStatusDistribution(t *testing.T) {
+	var config Config
+
+	distribution := StatusDistribution{
+		{Code: 404, Weight: 3},
+		{Code: 503, Weight: 1},
+	}
+
+	if err := config.SetStatusDistribution(distribution); err != nil {
+		t.Fatalf("set status distribution: %v", err)
+	}
+
+	// The Config keeps a copy of the distribution.
+	distribution[0].Weight = 10
+
+	wanted := StatusDistribution{
+		{Code: 404, Weight: 3},
+		{Code: 503, Weight: 1},
+	}
+
+	if diff := cmp.Diff(config.StatusDistribution(), wanted); diff != "" {
+		t.Fatalf("invalid status distribution:\n%s", diff)
+	}
+}
+
+func TestConfigStatusDistributionOnChange(t *testing.T) {
+	var (
+		config Config
+		got    []Settings
+	)
+
+	config.OnChange(func(s Settings) {
+		got = append(got, s)
+	})
+
+	distribution := StatusDistribution{{Code: 404, Weight: 1}}
+
+	for i := 0; i < 2; i++ {
+		if err := config.SetStatusDistribution(distribution); err != nil {
+			t.Fatalf("set status distribution: %v", err)
+		}
+	}
+
+	if err := config.SetStatusDistribution(nil); err != nil {
+		t.Fatalf("reset status distribution: %v", err)
+	}
+
+	wanted := []Settings{
+		{StatusDistribution: distribution, StatusDistributionVersion: 1},
+		{StatusDistributionVersion: 2},
+	}
+
+	if diff := cmp.Diff(got, wanted); diff != "" {
+		t.Fatalf("invalid settings:\n%s", diff)
+	}
+
+	if got := config.Snapshot().StatusDistribution; got != nil {
+		t.Fatalf("status distribution not reset: %v", got)
+	}
+}
+
+func TestConfigSetStatusDistributionError(t *testing.T) {
+	tests := []struct {
+		name         string
+		distribution StatusDistribution
+	}{
+		{
+			name:         "success-code",
+			distribution: StatusDistribution{{Code: 200, Weight: 1}},
+		},
+		{
+			name:         "invalid-code",
+			distribution: StatusDistribution{{Code: 600, Weight: 1}},
+		},
+		{
+			name:         "negative-weight",
+			distribution: StatusDistribution{{Code: 500, Weight: -1}},
+		},
+		{
+			name:         "zero-weights",
+			distribution: StatusDistribution{{Code: 500, Weight: 0}},
+		},
+		{
+			name:         "duplicate-code",
+			distribution: StatusDistribution{{Code: 500, Weight: 1}, {Code: 500, Weight: 2}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config Config
+
+			if err := config.SetStatusDistribution(test.distribution); err == nil {
+				t.Fatalf("no error returned")
+			}
+
+			if got := config.StatusDistribution(); got != nil {
+				t.Fatalf("status distribution changed after invalid value: %v", got)
+			}
+		})
+	}
+}
+
+func TestConfigSetDurationScale(t *testing.T) {
+	var config Config
+
+	if err := config.SetDurationScale(2.5); err != nil {
+		t.Fatalf("set duration scale: %v", err)
+	}
+
+	for _, scale := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if err := config.SetDurationScale(scale); err == nil {
+			t.Fatalf("no error returned for %v", scale)
+		}
+	}
+
+	if got := config.DurationScale(); got != 2.5 {
+		t.Fatalf("invalid duration scale: %v", got)
+	}
+}
+
+func TestConfigDurationIntervalSeconds(t *testing.T) {
+	var config Config
+
+	if err := config.SetDurationIntervalSeconds(0.05, 1.5); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	if min, max := config.DurationIntervalSeconds(); min != 0.05 || max != 1.5 {
+		t.Fatalf("invalid duration interval: %v,%v", min, max)
+	}
+
+	if min, max := config.DurationInterval(); min != 0 || max != 1 {
+		t.Fatalf("invalid truncated duration interval: %v,%v", min, max)
+	}
+
+	for _, interval := range [][2]float64{
+		{0, 1.5},
+		{math.NaN(), math.NaN()},
+		{1, math.Inf(1)},
+		{math.Inf(-1), 1},
+	} {
+		if err := config.SetDurationIntervalSeconds(interval[0], interval[1]); err == nil {
+			t.Fatalf("no error returned for %v", interval)
+		}
+	}
+
+	if min, max := config.DurationIntervalSeconds(); min != 0.05 || max != 1.5 {
+		t.Fatalf("duration interval changed after invalid value: %v,%v", min, max)
+	}
+}
+
+func TestConfigOnChange(t *testing.T) {
+	var (
+		config Config
+		got    []Settings
+	)
+
+	config.OnChange(func(s Settings) {
+		got = append(got, s)
+	})
+
+	if err := config.SetDurationInterval(1, 10); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	if err := config.SetErrorsPercentage(20); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	if err := config.SetDurationInterval(10, 1); err == nil {
+		t.Fatalf("no error returned")
+	}
+
+	if err := config.SetErrorsPercentage(101); err == nil {
+		t.Fatalf("no error returned")
+	}
+
+	wanted := []Settings{
+		{
+			MinDuration: 1,
+			MaxDuration: 10,
+		},
+		{
+			MinDuration:      1,
+			MaxDuration:      10,
+			ErrorsPercentage: 20,
+		},
+	}
+
+	if diff := cmp.Diff(got, wanted); diff != "" {
+		t.Fatalf("invalid settings:\n%s", diff)
+	}
+}
+
+func TestConfigOnChangeSameValue(t *testing.T) {
+	var config Config
+
+	if err := config.SetDurationInterval(1, 10); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	if err := config.SetRequestRate(1); err != nil {
+		t.Fatalf("set request rate: %v", err)
+	}
+
+	if err := config.SetDurationScale(1); err != nil {
+		t.Fatalf("set duration scale: %v", err)
+	}
+
+	if err := config.SetDurationLambda(1); err != nil {
+		t.Fatalf("set duration lambda: %v", err)
+	}
+
+	if err := config.SetErrorsPercentage(20); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	_, version := config.VersionedErrorsPercentage()
+
+	var calls int
+
+	config.OnChange(func(Settings) {
+		calls++
+	})
+
+	if err := config.SetErrorsPercentage(20); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	err := config.Update(func(s Settings) (Settings, error) {
+		return s, nil
+	})
+
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if calls != 0 {
+		t.Fatalf("callback called %d times", calls)
+	}
+
+	if _, got := config.VersionedErrorsPercentage(); got != version {
+		t.Fatalf("version changed without a change of value")
+	}
+}
+
+func BenchmarkConfigReads(b *testing.B) {
+	var config Config
+
+	b.Run("getters", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			config.DurationInterval()
+			config.ErrorsPercentage()
+			config.Capacity()
+			config.ClientErrorRatio()
+			config.RequestRate()
+		}
+	})
+
+	b.Run("snapshot", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			config.Snapshot()
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package metrics
+
+// BucketGuarantee guarantees that every bucket of a histogram receives at least
+// one observation per cycle. Every cycle starts by observing the middle of
+// every bucket, in order, then observes the durations of the simulated
+// requests for the rest of the cycle. This populates every bucket of the
+// histogram quickly.
+//
+// The guaranteed durations replace the durations of the simulated requests
+// after the duration scale and the fast error duration are applied, and the
+// cycle only advances for observations that are actually recorded, so that
+// the guarantee holds regardless of the other options of the Generator.
+type BucketGuarantee struct {
+	// Buckets are the upper bounds of the buckets, in increasing order. The
+	// first bucket starts at zero. If Buckets is empty, the guarantee is
+	// disabled.
+	Buckets []float64
+
+	// Cycle is the number of observations in a cycle. If Cycle is less than
+	// the number of buckets, every cycle is as long as the number of buckets.
+	Cycle int
+
+	next int
+}
+
+func (b *BucketGuarantee) enabled() bool {
+	return len(b.Buckets) > 0
+}
+
+// duration advances the cycle by one observation, and returns the duration
+// guaranteed for that observation, or false if the observation can use the
+// duration of the simulated request.
+func (b *BucketGuarantee) duration() (float64, bool) {
+	i := b.next
+
+	b.next++
+
+	if b.next >= b.Cycle && b.next >= len(b.Buckets) {
+		b.next = 0
+	}
+
+	if i >= len(b.Buckets) {
+		return 0, false
+	}
+
+	var lower float64
+
+	if i > 0 {
+		lower = b.Buckets[i-1]
+	}
+
+	return (lower + b.Buckets[i]) / 2, true
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestBucketGuaranteeDuration(t *testing.T) {
+	guarantee := BucketGuarantee{
+		Buckets: []float64{0.1, 0.5, 1, 5},
+		Cycle:   6,
+	}
+
+	var durations []float64
+
+	for i := 0; i < 14; i++ {
+		duration, ok := guarantee.duration()
+		if !ok {
+			duration = -1
+		}
+
+		durations = append(durations, duration)
+	}
+
+	wanted := []float64{
+		0.05, 0.3, 0.75, 3, -1, -1,
+		0.05, 0.3, 0.75, 3, -1, -1,
+		0.05, 0.3,
+	}
+
+	if diff := cmp.Diff(durations, wanted); diff != "" {
+		t.Fatalf("invalid durations:\n%s", diff)
+	}
+}
+
+func TestBucketGuaranteeShortCycle(t *testing.T) {
+	guarantee := BucketGuarantee{
+		Buckets: []float64{1, 2},
+		Cycle:   1,
+	}
+
+	var durations []float64
+
+	for i := 0; i < 4; i++ {
+		duration, _ := guarantee.duration()
+		durations = append(durations, duration)
+	}
+
+	if diff := cmp.Diff(durations, []float64{0.5, 1.5, 0.5, 1.5}); diff != "" {
+		t.Fatalf("invalid durations:\n%s", diff)
+	}
+}
+
+func TestGeneratorBucketGuarantee(t *testing.T) {
+	buckets := []float64{0.1, 0.5, 1, 5}
+
+	tests := []struct {
+		name      string
+		configure func(t *testing.T, g *Generator)
+	}{
+		{
+			// The scale would move every duration out of the lower buckets if
+			// it applied to the guaranteed durations.
+			name: "duration-scale",
+			configure: func(t *testing.T, g *Generator) {
+				if err := g.Config.SetDurationScale(100); err != nil {
+					t.Fatalf("set duration scale: %v", err)
+				}
+			},
+		},
+		{
+			name: "fast-errors",
+			configure: func(t *testing.T, g *Generator) {
+				if err := g.Config.SetErrorsPercentage(100); err != nil {
+					t.Fatalf("set errors percentage: %v", err)
+				}
+
+				g.FastErrorDuration = 100
+			},
+		},
+		{
+			// Every other request is dropped. If dropped observations
+			// advanced the cycle, half of the buckets would be skipped.
+			name: "max-observation-rate",
+			configure: func(t *testing.T, g *Generator) {
+				g.MaxObservationRate = 1
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "duration",
+				Buckets: buckets,
+			}, nil)
+
+			generator := Generator{
+				Config:   newTestConfig(t),
+				Duration: duration,
+				Errors:   newTestErrors(),
+				BucketGuarantee: BucketGuarantee{
+					Buckets: buckets,
+					Cycle:   len(buckets),
+				},
+			}
+
+			test.configure(t, &generator)
+
+			for i := 0; i < 2*len(buckets); i++ {
+				generator.simulateRequest(time.Duration(i) * time.Second / 2)
+			}
+
+			checkEveryBucketObserved(t, writeHistogram(t, duration, prometheus.Labels{}))
+		})
+	}
+}
+
+func checkEveryBucketObserved(t *testing.T, m *dto.Histogram) {
+	t.Helper()
+
+	var previous uint64
+
+	for _, bucket := range m.GetBucket() {
+		if bucket.GetCumulativeCount() <= previous {
+			t.Fatalf("no observations in bucket %v", bucket.GetUpperBound())
+		}
+
+		previous = bucket.GetCumulativeCount()
+	}
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import "time"
+
+// ErrorBurst periodically raises the errors percentage for a short time,
+// simulating incidents that alerts are expected to catch. Between bursts, the
+// errors percentage returns to the configured one.
+type ErrorBurst struct {
+	// Period is how often a burst happens. If Period is zero, bursts are
+	// disabled.
+	Period time.Duration
+
+	// Duration is how long a burst lasts. Every burst happens at the end of
+	// its period, so the Generator starts with the configured errors
+	// percentage.
+	Duration time.Duration
+
+	// ErrorsPercentage is the errors percentage during a burst.
+	ErrorsPercentage int
+}
+
+func (b ErrorBurst) enabled() bool {
+	return b.Period > 0
+}
+
+// errorsPercentage returns the errors percentage after the given time has
+// elapsed since the Generator started, given the errors percentage outside of
+// the bursts. A burst never lowers the errors percentage.
+func (b ErrorBurst) errorsPercentage(elapsed time.Duration, base int) int {
+	if !b.enabled() || elapsed%b.Period < b.Period-b.Duration {
+		return base
+	}
+
+	if b.ErrorsPercentage > base {
+		return b.ErrorsPercentage
+	}
+
+	return base
+}
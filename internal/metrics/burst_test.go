@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestErrorBurst(t *testing.T) {
+	burst := ErrorBurst{
+		Period:           5 * time.Minute,
+		Duration:         10 * time.Second,
+		ErrorsPercentage: 100,
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		wanted  int
+	}{
+		{elapsed: 0, wanted: 10},
+		{elapsed: 4*time.Minute + 49*time.Second, wanted: 10},
+		{elapsed: 4*time.Minute + 50*time.Second, wanted: 100},
+		{elapsed: 4*time.Minute + 59*time.Second, wanted: 100},
+		{elapsed: 5 * time.Minute, wanted: 10},
+		{elapsed: 9*time.Minute + 55*time.Second, wanted: 100},
+		{elapsed: 10*time.Minute + time.Second, wanted: 10},
+	}
+
+	for _, test := range tests {
+		if got := burst.errorsPercentage(test.elapsed, 10); got != test.wanted {
+			t.Fatalf("invalid errors percentage after %v: wanted %d, got %d", test.elapsed, test.wanted, got)
+		}
+	}
+}
+
+func TestErrorBurstDisabled(t *testing.T) {
+	var burst ErrorBurst
+
+	if got := burst.errorsPercentage(time.Hour, 10); got != 10 {
+		t.Fatalf("invalid errors percentage: %d", got)
+	}
+}
+
+func TestErrorBurstLowerPercentage(t *testing.T) {
+	burst := ErrorBurst{
+		Period:           time.Minute,
+		Duration:         time.Minute,
+		ErrorsPercentage: 5,
+	}
+
+	if got := burst.errorsPercentage(0, 10); got != 10 {
+		t.Fatalf("invalid errors percentage: %d", got)
+	}
+}
+
+func TestGeneratorErrorBurst(t *testing.T) {
+	errors := newTestErrors()
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: newTestDuration(),
+		Errors:   errors,
+		ErrorBurst: ErrorBurst{
+			Period:           time.Minute,
+			Duration:         10 * time.Second,
+			ErrorsPercentage: 100,
+		},
+	}
+
+	// One request per second for two periods. The configured errors
+	// percentage is zero, so only the requests during the bursts fail.
+	for i := 0; i < 120; i++ {
+		generator.simulateRequest(time.Duration(i) * time.Second)
+	}
+
+	if got := testutil.ToFloat64(errors.WithLabelValues(statusClassServerError)); got != 20 {
+		t.Fatalf("invalid number of errors: %v", got)
+	}
+}
+
+// timedCounterVec returns counters recording the time of every increment.
+type timedCounterVec struct {
+	clock Clock
+	times []time.Time
+}
+
+func (v *timedCounterVec) GetMetricWithLabelValues(...string) (prometheus.Counter, error) {
+	return timedCounter{Counter: prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}), vec: v}, nil
+}
+
+type timedCounter struct {
+	prometheus.Counter
+	vec *timedCounterVec
+}
+
+func (c timedCounter) Inc() {
+	c.vec.times = append(c.vec.times, c.vec.clock.Now())
+}
+
+func TestGeneratorErrorBurstWithClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Unix(1000, 0)
+
+	clock := &fakeClock{
+		now:    start,
+		ticks:  10 * 60,
+		cancel: cancel,
+	}
+
+	errors := timedCounterVec{
+		clock: clock,
+	}
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: newTestDuration(),
+		Errors:   &errors,
+		Clock:    clock,
+		ErrorBurst: ErrorBurst{
+			Period:           5 * time.Minute,
+			Duration:         30 * time.Second,
+			ErrorsPercentage: 100,
+		},
+	}
+
+	if err := generator.Run(ctx); err != context.Canceled {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	// Ten minutes at one request per second include two bursts of 30s.
+	if len(errors.times) != 60 {
+		t.Fatalf("invalid number of errors: %d", len(errors.times))
+	}
+
+	for _, at := range errors.times {
+		if elapsed := at.Sub(start) % (5 * time.Minute); elapsed < 4*time.Minute+30*time.Second {
+			t.Fatalf("error outside of a burst at %v", at.Sub(start))
+		}
+	}
+}
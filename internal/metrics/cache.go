@@ -0,0 +1,99 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// labelValuesSeparator separates label values in the keys of observerCache. It
+// is not a valid UTF-8 byte, so it can't be part of a label value.
+const labelValuesSeparator = 0xff
+
+// observerCache caches observers and counters by their label values. Looking
+// up a cached observer doesn't allocate memory.
+type observerCache struct {
+	observers map[string]prometheus.Observer
+	counters  map[string]prometheus.Counter
+	key       []byte
+}
+
+// observer returns the cached observer for the label values, resolving it
+// from vec if needed. Observers that can't be resolved are not cached.
+func (c *observerCache) observer(vec HistogramVec, labelValues []string) (prometheus.Observer, error) {
+	key := c.keyOf(labelValues)
+
+	if observer, ok := c.observers[string(key)]; ok {
+		return observer, nil
+	}
+
+	observer, err := vec.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.observers == nil {
+		c.observers = make(map[string]prometheus.Observer)
+	}
+
+	c.observers[string(key)] = observer
+	return observer, nil
+}
+
+// counter returns the cached counter for the label values, resolving it from
+// vec if needed. Counters that can't be resolved are not cached.
+func (c *observerCache) counter(vec CounterVec, labelValues []string) (prometheus.Counter, error) {
+	key := c.keyOf(labelValues)
+
+	if counter, ok := c.counters[string(key)]; ok {
+		return counter, nil
+	}
+
+	counter, err := vec.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.counters == nil {
+		c.counters = make(map[string]prometheus.Counter)
+	}
+
+	c.counters[string(key)] = counter
+	return counter, nil
+}
+
+// keyOf returns the key for the label values. The key is only valid until the
+// next call to keyOf.
+func (c *observerCache) keyOf(labelValues []string) []byte {
+	c.key = appendKey(c.key[:0], labelValues)
+	return c.key
+}
+
+// appendKey appends to dst a key that uniquely identifies the label values.
+func appendKey(dst []byte, labelValues []string) []byte {
+	for _, value := range labelValues {
+		dst = append(dst, value...)
+		dst = append(dst, labelValuesSeparator)
+	}
+
+	return dst
+}
+
+// seriesSet tracks the distinct combinations of label values.
+type seriesSet struct {
+	seen map[string]struct{}
+	key  []byte
+}
+
+// add records the label values and returns the number of distinct
+// combinations recorded so far.
+func (s *seriesSet) add(labelValues []string) int {
+	s.key = appendKey(s.key[:0], labelValues)
+
+	if _, ok := s.seen[string(s.key)]; ok {
+		return len(s.seen)
+	}
+
+	if s.seen == nil {
+		s.seen = make(map[string]struct{})
+	}
+
+	s.seen[string(s.key)] = struct{}{}
+	return len(s.seen)
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserverCache(t *testing.T) {
+	var cache observerCache
+
+	duration := newTestDuration(TenantLabel, BuildIDLabel)
+
+	cachedObserver(t, &cache, duration, []string{"acme", "build-1"}).Observe(1)
+	cachedObserver(t, &cache, duration, []string{"acme", "build-2"}).Observe(2)
+	cachedObserver(t, &cache, duration, []string{"acme", "build-1"}).Observe(3)
+	cachedObserver(t, &cache, duration, []string{"globex", "build-1"}).Observe(4)
+
+	if got := len(cache.observers); got != 3 {
+		t.Fatalf("invalid number of cached observers: %d", got)
+	}
+
+	tests := []struct {
+		labels prometheus.Labels
+		sum    float64
+	}{
+		{labels: prometheus.Labels{TenantLabel: "acme", BuildIDLabel: "build-1"}, sum: 4},
+		{labels: prometheus.Labels{TenantLabel: "acme", BuildIDLabel: "build-2"}, sum: 2},
+		{labels: prometheus.Labels{TenantLabel: "globex", BuildIDLabel: "build-1"}, sum: 4},
+	}
+
+	for _, test := range tests {
+		if got := histogramSum(t, duration, test.labels); got != test.sum {
+			t.Fatalf("invalid sum for %v: wanted %v, got %v", test.labels, test.sum, got)
+		}
+	}
+}
+
+func TestObserverCacheCounter(t *testing.T) {
+	var cache observerCache
+
+	errors := newTestErrors(TenantLabel)
+
+	cachedCounter(t, &cache, errors, []string{"acme", statusClassServerError}).Inc()
+	cachedCounter(t, &cache, errors, []string{"acme", statusClassClientError}).Inc()
+	cachedCounter(t, &cache, errors, []string{"acme", statusClassServerError}).Inc()
+
+	if got := testutil.ToFloat64(errors.WithLabelValues("acme", statusClassServerError)); got != 2 {
+		t.Fatalf("invalid number of server errors: %v", got)
+	}
+
+	if got := testutil.ToFloat64(errors.WithLabelValues("acme", statusClassClientError)); got != 1 {
+		t.Fatalf("invalid number of client errors: %v", got)
+	}
+}
+
+func TestObserverCacheKeys(t *testing.T) {
+	var cache observerCache
+
+	duration := newTestDuration(TenantLabel, BuildIDLabel)
+
+	// Concatenating the label values would produce the same key.
+	cachedObserver(t, &cache, duration, []string{"ab", "c"})
+	cachedObserver(t, &cache, duration, []string{"a", "bc"})
+
+	if got := len(cache.observers); got != 2 {
+		t.Fatalf("invalid number of cached observers: %d", got)
+	}
+}
+
+func TestObserverCacheError(t *testing.T) {
+	var cache observerCache
+
+	duration := newTestDuration(TenantLabel)
+
+	if _, err := cache.observer(duration, []string{"acme", "build-1"}); err == nil {
+		t.Fatalf("no error returned")
+	}
+
+	if got := len(cache.observers); got != 0 {
+		t.Fatalf("invalid number of cached observers: %d", got)
+	}
+}
+
+func cachedObserver(t *testing.T, cache *observerCache, vec HistogramVec, labelValues []string) prometheus.Observer {
+	t.Helper()
+
+	observer, err := cache.observer(vec, labelValues)
+	if err != nil {
+		t.Fatalf("resolve observer: %v", err)
+	}
+
+	return observer
+}
+
+func cachedCounter(t *testing.T, cache *observerCache, vec CounterVec, labelValues []string) prometheus.Counter {
+	t.Helper()
+
+	counter, err := cache.counter(vec, labelValues)
+	if err != nil {
+		t.Fatalf("resolve counter: %v", err)
+	}
+
+	return counter
+}
+
+func BenchmarkSimulateRequest(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) {
+		benchmarkSimulateRequest(b, false)
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		benchmarkSimulateRequest(b, true)
+	})
+}
+
+func benchmarkSimulateRequest(b *testing.B, cacheObservers bool) {
+	var config limits.Config
+
+	if err := config.SetDurationInterval(1, 10); err != nil {
+		b.Fatalf("set duration interval: %v", err)
+	}
+
+	if err := config.SetErrorsPercentage(50); err != nil {
+		b.Fatalf("set errors percentage: %v", err)
+	}
+
+	tenants, err := ParseTenants("acme:1,globex:2,initech:3")
+	if err != nil {
+		b.Fatalf("parse tenants: %v", err)
+	}
+
+	generator := Generator{
+		Config:         &config,
+		Duration:       newTestDuration(TenantLabel),
+		Errors:         newTestErrors(TenantLabel),
+		Tenants:        tenants,
+		CacheObservers: cacheObservers,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		generator.simulateRequest(0)
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// weightedChooser picks indexes at random, with a probability proportional to
+// the weight of every index. Indexes with a weight of zero are never picked.
+type weightedChooser struct {
+	// cumulative contains, for every index, the sum of the weights up to and
+	// including that index.
+	cumulative []int
+}
+
+func newWeightedChooser(weights []int) weightedChooser {
+	cumulative := make([]int, len(weights))
+
+	var total int
+
+	for i, weight := range weights {
+		total += weight
+		cumulative[i] = total
+	}
+
+	return weightedChooser{cumulative: cumulative}
+}
+
+// uniformChooser returns a chooser picking n indexes with the same probability.
+func uniformChooser(n int) weightedChooser {
+	weights := make([]int, n)
+
+	for i := range weights {
+		weights[i] = 1
+	}
+
+	return newWeightedChooser(weights)
+}
+
+// total returns the sum of the weights.
+func (c weightedChooser) total() int {
+	if len(c.cumulative) == 0 {
+		return 0
+	}
+
+	return c.cumulative[len(c.cumulative)-1]
+}
+
+// choose picks an index at random using r. The sum of the weights must be
+// greater than zero.
+func (c weightedChooser) choose(r *rand.Rand) int {
+	return c.index(r.Intn(c.total()))
+}
+
+// index returns the index whose cumulative weight range includes n, which must
+// be in [0, c.total()).
+func (c weightedChooser) index(n int) int {
+	return sort.SearchInts(c.cumulative, n+1)
+}
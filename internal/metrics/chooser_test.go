@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWeightedChooserIndex(t *testing.T) {
+	chooser := newWeightedChooser([]int{2, 0, 1, 3})
+
+	var indexes []int
+
+	for n := 0; n < chooser.total(); n++ {
+		indexes = append(indexes, chooser.index(n))
+	}
+
+	if diff := cmp.Diff(indexes, []int{0, 0, 2, 3, 3, 3}); diff != "" {
+		t.Fatalf("invalid indexes:\n%s", diff)
+	}
+}
+
+func TestWeightedChooserFrequencies(t *testing.T) {
+	const samples = 100000
+
+	weights := []int{5, 0, 3, 2}
+
+	chooser := newWeightedChooser(weights)
+
+	r := rand.New(rand.NewSource(1))
+
+	counts := make([]int, len(weights))
+
+	for i := 0; i < samples; i++ {
+		counts[chooser.choose(r)]++
+	}
+
+	for i, weight := range weights {
+		wanted := float64(weight) / float64(chooser.total())
+
+		if got := float64(counts[i]) / samples; math.Abs(got-wanted) > 0.01 {
+			t.Fatalf("invalid frequency of index %d: wanted %v, got %v", i, wanted, got)
+		}
+	}
+}
+
+func TestWeightedChooserEmpty(t *testing.T) {
+	if got := newWeightedChooser(nil).total(); got != 0 {
+		t.Fatalf("invalid total: %d", got)
+	}
+}
+
+func TestUniformChooserIndex(t *testing.T) {
+	chooser := uniformChooser(3)
+
+	var indexes []int
+
+	for n := 0; n < chooser.total(); n++ {
+		indexes = append(indexes, chooser.index(n))
+	}
+
+	if diff := cmp.Diff(indexes, []int{0, 1, 2}); diff != "" {
+		t.Fatalf("invalid indexes:\n%s", diff)
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+)
+
+// Churn periodically changes the value of the BuildIDLabel label, simulating
+// the deployment of a new build. Every change creates new series and lets the
+// old ones go stale.
+type Churn struct {
+	// Interval is how often the value of the label changes. If Interval is
+	// zero, churn is disabled.
+	Interval time.Duration
+
+	// MaxValues is a safety cap on the number of distinct values of the label.
+	// Once the cap is reached, churn starts again from the first value.
+	MaxValues int
+}
+
+func (c Churn) enabled() bool {
+	return c.Interval > 0
+}
+
+// value returns the value of the label after the given time has elapsed since
+// the Generator started.
+func (c Churn) value(elapsed time.Duration) string {
+	n := int64(elapsed / c.Interval)
+
+	if c.MaxValues > 0 {
+		n %= int64(c.MaxValues)
+	}
+
+	return fmt.Sprintf("build-%d", n)
+}
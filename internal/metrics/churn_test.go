@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestChurnValue(t *testing.T) {
+	churn := Churn{
+		Interval:  2 * time.Second,
+		MaxValues: 3,
+	}
+
+	var values []string
+
+	for elapsed := time.Duration(0); elapsed < 8*time.Second; elapsed += time.Second {
+		values = append(values, churn.value(elapsed))
+	}
+
+	wanted := []string{
+		"build-0", "build-0",
+		"build-1", "build-1",
+		"build-2", "build-2",
+		"build-0", "build-0",
+	}
+
+	if diff := cmp.Diff(values, wanted); diff != "" {
+		t.Fatalf("invalid values:\n%s", diff)
+	}
+}
+
+func TestGeneratorChurn(t *testing.T) {
+	duration := newTestDuration(BuildIDLabel)
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: duration,
+		Errors:   newTestErrors(BuildIDLabel),
+		Churn: Churn{
+			Interval:  2 * time.Second,
+			MaxValues: 10,
+		},
+	}
+
+	runWithFakeClock(t, &generator, 5)
+
+	if got := testutil.CollectAndCount(duration); got != 3 {
+		t.Fatalf("invalid number of series: %d", got)
+	}
+
+	for _, value := range []string{"build-0", "build-1", "build-2"} {
+		if got := histogramCount(t, duration, prometheus.Labels{BuildIDLabel: value}); got != 2 {
+			t.Fatalf("invalid number of observations for %s: %d", value, got)
+		}
+	}
+}
+
+func TestGeneratorSeenSeries(t *testing.T) {
+	seenSeries := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "test_seen_series",
+	})
+
+	generator := Generator{
+		Config:     newTestConfig(t),
+		Duration:   newTestDuration(BuildIDLabel),
+		Errors:     newTestErrors(BuildIDLabel),
+		SeenSeries: seenSeries,
+		Churn: Churn{
+			Interval:  2 * time.Second,
+			MaxValues: 10,
+		},
+	}
+
+	var got []float64
+
+	for elapsed := time.Duration(0); elapsed < 6*time.Second; elapsed += time.Second {
+		generator.simulateRequest(elapsed)
+		got = append(got, testutil.ToFloat64(seenSeries))
+	}
+
+	if diff := cmp.Diff(got, []float64{1, 1, 2, 2, 3, 3}); diff != "" {
+		t.Fatalf("invalid number of seen series:\n%s", diff)
+	}
+}
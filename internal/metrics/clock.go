@@ -0,0 +1,26 @@
+package metrics
+
+import "time"
+
+// Clock tells the time to the Generator and lets it wait for time to pass.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+
+	// AfterFunc calls f in its own goroutine after d has elapsed.
+	AfterFunc(d time.Duration, f func())
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) {
+	time.AfterFunc(d, f)
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import "github.com/francescomari/metrics-generator/internal/limits"
+
+// ExportDurationInterval sets min and max to the minimum and maximum duration
+// of config, in seconds, and keeps them up to date when config changes.
+func ExportDurationInterval(config *limits.Config, min, max Gauge) {
+	set := func(settings limits.Settings) {
+		min.Set(settings.MinDuration)
+		max.Set(settings.MaxDuration)
+	}
+
+	config.OnChange(set)
+
+	set(config.Snapshot())
+}
+
+// ExportErrorsPercentage sets errorsPercentage to the errors percentage of
+// config and keeps it up to date when config changes.
+func ExportErrorsPercentage(config *limits.Config, errorsPercentage Gauge) {
+	set := func(settings limits.Settings) {
+		errorsPercentage.Set(float64(settings.ErrorsPercentage))
+	}
+
+	config.OnChange(set)
+
+	set(config.Snapshot())
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExportDurationInterval(t *testing.T) {
+	config := newTestConfig(t)
+
+	var min, max mockGauge
+
+	ExportDurationInterval(config, &min, &max)
+
+	if err := config.SetDurationInterval(2, 20); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	if diff := cmp.Diff(min.values, []float64{1, 2}); diff != "" {
+		t.Fatalf("invalid minimum duration values:\n%s", diff)
+	}
+
+	if diff := cmp.Diff(max.values, []float64{10, 20}); diff != "" {
+		t.Fatalf("invalid maximum duration values:\n%s", diff)
+	}
+}
+
+func TestExportErrorsPercentage(t *testing.T) {
+	config := newTestConfig(t)
+
+	var errorsPercentage mockGauge
+
+	ExportErrorsPercentage(config, &errorsPercentage)
+
+	if err := config.SetErrorsPercentage(25); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	// Changes to other values of the configuration set the gauge again to the
+	// same value.
+	if err := config.SetDurationInterval(2, 20); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	if diff := cmp.Diff(errorsPercentage.values, []float64{0, 25, 25}); diff != "" {
+		t.Fatalf("invalid errors percentage values:\n%s", diff)
+	}
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultRateHistoryInterval is how often the rate is sampled if RateHistory
+// doesn't specify an interval.
+const defaultRateHistoryInterval = time.Second
+
+// RateHistory keeps the request rates used by the Generator, sampled at regular
+// intervals, so that the history covers a fixed amount of time regardless of
+// the rate. It is safe for concurrent use, so that the rates can be read while
+// the Generator records them.
+type RateHistory struct {
+	// Size is the maximum number of rates kept by the history.
+	Size int
+
+	// Interval is how often the rate is sampled. If Interval is not greater
+	// than zero, the rate is sampled every second.
+	Interval time.Duration
+
+	mu    sync.Mutex
+	rates []float64
+	next  int
+
+	// tick is the index of the next interval to sample, and last is the most
+	// recent rate passed to record.
+	tick int64
+	last float64
+}
+
+// record samples the rate of a request simulated after the given time has
+// elapsed since the Generator started. The first rate of every interval is
+// added to the history. Intervals without requests repeat the rate of the
+// previous request.
+func (h *RateHistory) record(elapsed time.Duration, rate float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tick := int64(elapsed / h.interval())
+
+	// Intervals older than the size of the history would be replaced anyway.
+	if tick-h.tick > int64(h.Size) {
+		h.tick = tick - int64(h.Size)
+	}
+
+	for ; h.tick < tick; h.tick++ {
+		h.add(h.last)
+	}
+
+	if h.tick == tick {
+		h.add(rate)
+		h.tick++
+	}
+
+	h.last = rate
+}
+
+func (h *RateHistory) interval() time.Duration {
+	if h.Interval <= 0 {
+		return defaultRateHistoryInterval
+	}
+
+	return h.Interval
+}
+
+// add adds a rate to the history, replacing the oldest one if the history is
+// full.
+func (h *RateHistory) add(rate float64) {
+	if len(h.rates) < h.Size {
+		h.rates = append(h.rates, rate)
+		return
+	}
+
+	if h.Size > 0 {
+		h.rates[h.next] = rate
+		h.next = (h.next + 1) % h.Size
+	}
+}
+
+// Rates returns the rates in the history, from the oldest to the most recent.
+func (h *RateHistory) Rates() []float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rates := make([]float64, 0, len(h.rates))
+	rates = append(rates, h.rates[h.next:]...)
+	rates = append(rates, h.rates[:h.next]...)
+
+	return rates
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRateHistory(t *testing.T) {
+	history := RateHistory{
+		Size: 3,
+	}
+
+	if diff := cmp.Diff(history.Rates(), []float64{}); diff != "" {
+		t.Fatalf("invalid rates:\n%s", diff)
+	}
+
+	for i, wanted := range [][]float64{
+		{1},
+		{1, 2},
+		{1, 2, 3},
+		{2, 3, 4},
+		{3, 4, 5},
+		{4, 5, 6},
+		{5, 6, 7},
+	} {
+		history.record(time.Duration(i)*time.Second, float64(i+1))
+
+		if diff := cmp.Diff(history.Rates(), wanted); diff != "" {
+			t.Fatalf("invalid rates after %d records:\n%s", i+1, diff)
+		}
+	}
+}
+
+func TestRateHistoryInterval(t *testing.T) {
+	history := RateHistory{
+		Size:     5,
+		Interval: time.Second,
+	}
+
+	// Only the first rate of every interval is sampled, and the intervals
+	// without requests repeat the rate of the previous request.
+	history.record(0, 1)
+	history.record(500*time.Millisecond, 2)
+	history.record(900*time.Millisecond, 3)
+	history.record(3500*time.Millisecond, 4)
+	history.record(4*time.Second, 5)
+
+	if diff := cmp.Diff(history.Rates(), []float64{1, 3, 3, 4, 5}); diff != "" {
+		t.Fatalf("invalid rates:\n%s", diff)
+	}
+
+	// A long pause fills the whole history with the rate before the pause.
+	history.record(time.Hour, 6)
+
+	if diff := cmp.Diff(history.Rates(), []float64{5, 5, 5, 5, 6}); diff != "" {
+		t.Fatalf("invalid rates after a pause:\n%s", diff)
+	}
+}
+
+func TestGeneratorRateHistory(t *testing.T) {
+	var steps []Step
+
+	for i := 1; i <= 5; i++ {
+		steps = append(steps, Step{Rate: float64(i), Duration: time.Second})
+	}
+
+	history := RateHistory{
+		Size: 3,
+	}
+
+	generator := Generator{
+		Config:      newTestConfig(t),
+		Duration:    newTestDuration(),
+		Errors:      newTestErrors(),
+		Rate:        StepRate{Steps: steps},
+		RateHistory: &history,
+	}
+
+	for i := 0; i < 5; i++ {
+		generator.simulateRequest(time.Duration(i) * time.Second)
+	}
+
+	if diff := cmp.Diff(history.Rates(), []float64{3, 4, 5}); diff != "" {
+		t.Fatalf("invalid rates:\n%s", diff)
+	}
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// InvalidCollector exposes metrics that deliberately violate the Prometheus
+// naming and documentation conventions. It is meant to exercise linters and
+// validators, and must not be registered by default.
+//
+// InvalidCollector is an unchecked collector: it doesn't describe its metrics,
+// so the registry doesn't reject them for their unconventional names.
+type InvalidCollector struct{}
+
+var invalidMetrics = []struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	labels    []string
+}{
+	{
+		// Camel case in the name and in the label.
+		desc:      prometheus.NewDesc("metricsGenerator_invalidCamelCase", "A metric with camel case names", []string{"statusCode"}, nil),
+		valueType: prometheus.GaugeValue,
+		labels:    []string{"200"},
+	},
+	{
+		// Empty help.
+		desc:      prometheus.NewDesc("metrics_generator_invalid_empty_help", "", nil, nil),
+		valueType: prometheus.GaugeValue,
+	},
+	{
+		// Counter without the _total suffix.
+		desc:      prometheus.NewDesc("metrics_generator_invalid_requests", "A counter without the total suffix", nil, nil),
+		valueType: prometheus.CounterValue,
+	},
+	{
+		// Non-base unit.
+		desc:      prometheus.NewDesc("metrics_generator_invalid_duration_milliseconds", "A metric with a non-base unit", nil, nil),
+		valueType: prometheus.GaugeValue,
+	},
+	{
+		// Inconsistent label sets: the same metric is exposed once with the
+		// "method" label and once with the "path" label. The registry accepts
+		// it, because it only checks that the label values are unique.
+		desc:      prometheus.NewDesc(inconsistentLabelsName, "A metric with inconsistent label sets", []string{"method"}, nil),
+		valueType: prometheus.GaugeValue,
+		labels:    []string{"GET"},
+	},
+	{
+		desc:      prometheus.NewDesc(inconsistentLabelsName, "A metric with inconsistent label sets", []string{"path"}, nil),
+		valueType: prometheus.GaugeValue,
+		labels:    []string{"/"},
+	},
+}
+
+const inconsistentLabelsName = "metrics_generator_invalid_inconsistent_labels"
+
+func (InvalidCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (InvalidCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range invalidMetrics {
+		ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, 1, m.labels...)
+	}
+}
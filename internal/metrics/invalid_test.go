@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil/promlint"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestInvalidCollector(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+
+	if err := registry.Register(InvalidCollector{}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+
+	// The metrics with inconsistent label sets share the same family.
+	if len(families) != len(invalidMetrics)-1 {
+		t.Fatalf("invalid number of metric families: %d", len(families))
+	}
+
+	problems, err := promlint.NewWithMetricFamilies(families).Lint()
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+
+	// Every metric violates at least one convention. The linter reports only
+	// missing help texts, not empty ones.
+	invalid := make(map[string]bool)
+
+	for _, problem := range problems {
+		invalid[problem.Metric] = true
+	}
+
+	for _, family := range families {
+		// The linter doesn't check the consistency of the label sets.
+		if family.GetName() == inconsistentLabelsName {
+			checkInconsistentLabels(t, family)
+			continue
+		}
+
+		if !invalid[family.GetName()] && family.GetHelp() != "" {
+			t.Fatalf("no problems reported for %s", family.GetName())
+		}
+	}
+}
+
+func checkInconsistentLabels(t *testing.T, family *dto.MetricFamily) {
+	t.Helper()
+
+	var names []string
+
+	for _, metric := range family.GetMetric() {
+		for _, label := range metric.GetLabel() {
+			names = append(names, label.GetName())
+		}
+	}
+
+	if len(names) != 2 || names[0] == names[1] {
+		t.Fatalf("label sets are not inconsistent: %v", names)
+	}
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// labelNamePattern matches valid Prometheus label names.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabelNames are the names of the labels managed by the Generator or
+// by the histograms and summaries of the Prometheus client, which can't be used
+// by custom labels.
+var reservedLabelNames = map[string]bool{
+	TenantLabel:      true,
+	BuildIDLabel:     true,
+	StatusClassLabel: true,
+	EndpointLabel:    true,
+	MethodLabel:      true,
+	StatusLabel:      true,
+	CodeLabel:        true,
+	RouteLabel:       true,
+	"le":             true,
+	"quantile":       true,
+}
+
+// Label is a custom label attached to the metrics of the simulated requests.
+// Every simulated request picks one of the values of the label according to
+// their weight, so the label can't have more series than values.
+type Label struct {
+	Name   string
+	Values []LabelValue
+}
+
+// LabelValue is a possible value of a Label.
+type LabelValue struct {
+	Value string
+
+	// Weight is the share of the simulated requests with this value, relative
+	// to the weights of the other values of the label.
+	Weight int
+}
+
+// ParseLabels parses a comma-separated list of labels in the form
+// name=value[:weight]|value[:weight]... If omitted, the weight of a value is 1.
+func ParseLabels(value string) ([]Label, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var (
+		labels []Label
+		names  = make(map[string]bool)
+	)
+
+	for _, part := range strings.Split(value, ",") {
+		label, err := parseLabel(part)
+		if err != nil {
+			return nil, fmt.Errorf("label %q: %v", part, err)
+		}
+
+		if names[label.Name] {
+			return nil, fmt.Errorf("label %q: duplicate name", part)
+		}
+
+		names[label.Name] = true
+		labels = append(labels, label)
+	}
+
+	return labels, nil
+}
+
+func parseLabel(value string) (Label, error) {
+	fields := strings.Split(strings.TrimSpace(value), "=")
+
+	if len(fields) != 2 {
+		return Label{}, fmt.Errorf("not in the form name=value[:weight]|value[:weight]...")
+	}
+
+	name := fields[0]
+
+	if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+		return Label{}, fmt.Errorf("invalid name")
+	}
+
+	if reservedLabelNames[name] {
+		return Label{}, fmt.Errorf("reserved name")
+	}
+
+	label := Label{
+		Name: name,
+	}
+
+	values, err := parseLabelValues(strings.Split(fields[1], "|"))
+	if err != nil {
+		return Label{}, err
+	}
+
+	label.Values = values
+
+	return label, nil
+}
+
+// ParseRoutes parses a comma-separated list of routes in the form
+// route[:weight]. If omitted, the weight of a route is 1.
+func ParseRoutes(value string) ([]LabelValue, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var parts []string
+
+	for _, part := range strings.Split(value, ",") {
+		parts = append(parts, strings.TrimSpace(part))
+	}
+
+	return parseLabelValues(parts)
+}
+
+// ParseSuccessStatuses parses a comma-separated list of status codes of
+// successful requests in the form code[:weight]. The status codes must be
+// between 200 and 399. If omitted, the weight of a status code is 1.
+func ParseSuccessStatuses(value string) ([]LabelValue, error) {
+	statuses, err := ParseRoutes(value)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		code, err := strconv.Atoi(status.Value)
+		if err != nil || code < 200 || code > 399 || status.Value != strconv.Itoa(code) {
+			return nil, fmt.Errorf("status %q: not a status code between 200 and 399", status.Value)
+		}
+	}
+
+	return statuses, nil
+}
+
+func parseLabelValues(parts []string) ([]LabelValue, error) {
+	var (
+		values []LabelValue
+		seen   = make(map[string]bool)
+	)
+
+	for _, part := range parts {
+		labelValue, err := parseLabelValue(part)
+		if err != nil {
+			return nil, fmt.Errorf("value %q: %v", part, err)
+		}
+
+		if seen[labelValue.Value] {
+			return nil, fmt.Errorf("value %q: duplicate value", part)
+		}
+
+		seen[labelValue.Value] = true
+		values = append(values, labelValue)
+	}
+
+	return values, nil
+}
+
+func parseLabelValue(value string) (LabelValue, error) {
+	fields := strings.Split(value, ":")
+
+	if len(fields) > 2 {
+		return LabelValue{}, fmt.Errorf("not in the form value[:weight]")
+	}
+
+	if fields[0] == "" {
+		return LabelValue{}, fmt.Errorf("empty value")
+	}
+
+	labelValue := LabelValue{
+		Value:  fields[0],
+		Weight: 1,
+	}
+
+	if len(fields) == 2 {
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil || weight <= 0 {
+			return LabelValue{}, fmt.Errorf("weight is not a positive number")
+		}
+
+		labelValue.Weight = weight
+	}
+
+	return labelValue, nil
+}
+
+func labelValuesChooser(values []LabelValue) weightedChooser {
+	weights := make([]int, len(values))
+
+	for i, value := range values {
+		weights[i] = value.Weight
+	}
+
+	return newWeightedChooser(weights)
+}
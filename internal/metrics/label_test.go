@@ -0,0 +1,318 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels("region=eu-west|us-east,zone=a:3|b:1")
+	if err != nil {
+		t.Fatalf("parse labels: %v", err)
+	}
+
+	wanted := []Label{
+		{
+			Name: "region",
+			Values: []LabelValue{
+				{Value: "eu-west", Weight: 1},
+				{Value: "us-east", Weight: 1},
+			},
+		},
+		{
+			Name: "zone",
+			Values: []LabelValue{
+				{Value: "a", Weight: 3},
+				{Value: "b", Weight: 1},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(labels, wanted); diff != "" {
+		t.Fatalf("invalid labels:\n%s", diff)
+	}
+}
+
+func TestParseLabelsError(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{
+			name:  "no-values",
+			value: "region",
+		},
+		{
+			name:  "invalid-name",
+			value: "1region=eu",
+		},
+		{
+			name:  "internal-name",
+			value: "__region=eu",
+		},
+		{
+			name:  "reserved-name",
+			value: "tenant=acme",
+		},
+		{
+			name:  "reserved-code",
+			value: "code=a",
+		},
+		{
+			name:  "reserved-le",
+			value: "le=a",
+		},
+		{
+			name:  "reserved-quantile",
+			value: "quantile=a",
+		},
+		{
+			name:  "empty-value",
+			value: "region=eu|",
+		},
+		{
+			name:  "invalid-weight",
+			value: "region=eu:boom",
+		},
+		{
+			name:  "zero-weight",
+			value: "region=eu:0",
+		},
+		{
+			name:  "too-many-fields",
+			value: "region=eu:1:2",
+		},
+		{
+			name:  "duplicate-value",
+			value: "region=eu|eu",
+		},
+		{
+			name:  "duplicate-name",
+			value: "region=eu,region=us",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseLabels(test.value); err == nil {
+				t.Fatalf("no error returned")
+			}
+		})
+	}
+}
+
+func TestGeneratorLabels(t *testing.T) {
+	const requests = 1000
+
+	labels, err := ParseLabels("region=eu|us,zone=a:3|b:1")
+	if err != nil {
+		t.Fatalf("parse labels: %v", err)
+	}
+
+	generator := Generator{
+		Config: newTestConfig(t),
+		Labels: labels,
+	}
+
+	if diff := cmp.Diff(generator.LabelNames(), []string{"region", "zone"}); diff != "" {
+		t.Fatalf("invalid label names:\n%s", diff)
+	}
+
+	duration := newTestDuration(generator.LabelNames()...)
+
+	generator.Duration = duration
+	generator.Errors = newTestErrors(generator.LabelNames()...)
+
+	for i := 0; i < requests; i++ {
+		generator.simulateRequest(0)
+	}
+
+	if got := testutil.CollectAndCount(duration); got != 4 {
+		t.Fatalf("invalid number of series: %d", got)
+	}
+
+	var zoneARequests int
+
+	for _, region := range []string{"eu", "us"} {
+		zoneARequests += histogramCount(t, duration, prometheus.Labels{"region": region, "zone": "a"})
+	}
+
+	if zoneARequests < 650 || zoneARequests > 850 {
+		t.Fatalf("invalid number of requests for zone a: %d", zoneARequests)
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes("/users:3, /orders")
+	if err != nil {
+		t.Fatalf("parse routes: %v", err)
+	}
+
+	wanted := []LabelValue{
+		{Value: "/users", Weight: 3},
+		{Value: "/orders", Weight: 1},
+	}
+
+	if diff := cmp.Diff(routes, wanted); diff != "" {
+		t.Fatalf("invalid routes:\n%s", diff)
+	}
+}
+
+func TestParseRoutesError(t *testing.T) {
+	for _, value := range []string{"/users,", "/users:0", "/users,/users"} {
+		if _, err := ParseRoutes(value); err == nil {
+			t.Fatalf("no error returned for %q", value)
+		}
+	}
+}
+
+func TestLabelValuesChooser(t *testing.T) {
+	values := []LabelValue{
+		{Value: "/users", Weight: 3},
+		{Value: "/orders", Weight: 1},
+	}
+
+	chooser := labelValuesChooser(values)
+
+	var picked []string
+
+	for n := 0; n < chooser.total(); n++ {
+		picked = append(picked, values[chooser.index(n)].Value)
+	}
+
+	if diff := cmp.Diff(picked, []string{"/users", "/users", "/users", "/orders"}); diff != "" {
+		t.Fatalf("invalid values:\n%s", diff)
+	}
+}
+
+func TestGeneratorRoutes(t *testing.T) {
+	const requests = 1000
+
+	var (
+		duration = newTestDuration(RouteLabel)
+		errors   = newTestErrors(RouteLabel)
+		counter  = newTestRequests(RouteLabel)
+	)
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: duration,
+		Errors:   errors,
+		Requests: counter,
+		Routes: []LabelValue{
+			{Value: "/users", Weight: 3},
+			{Value: "/orders", Weight: 1},
+		},
+	}
+
+	for i := 0; i < requests; i++ {
+		generator.simulateRequest(0)
+	}
+
+	usersRequests := histogramCount(t, duration, prometheus.Labels{RouteLabel: "/users"})
+	ordersRequests := histogramCount(t, duration, prometheus.Labels{RouteLabel: "/orders"})
+
+	if usersRequests+ordersRequests != requests {
+		t.Fatalf("invalid number of requests: %d", usersRequests+ordersRequests)
+	}
+
+	if usersRequests < 650 || usersRequests > 850 {
+		t.Fatalf("invalid number of requests for /users: %d", usersRequests)
+	}
+
+	usersCount := testutil.ToFloat64(counter.With(prometheus.Labels{
+		RouteLabel:    "/users",
+		EndpointLabel: defaultEndpoint,
+		MethodLabel:   defaultMethod,
+		StatusLabel:   statusClassSuccess,
+		CodeLabel:     statusCodeSuccess,
+	}))
+
+	if int(usersCount) != usersRequests {
+		t.Fatalf("invalid number of counted requests for /users: wanted %d, got %v", usersRequests, usersCount)
+	}
+}
+
+func TestParseSuccessStatuses(t *testing.T) {
+	statuses, err := ParseSuccessStatuses("200:8,201,301:2")
+	if err != nil {
+		t.Fatalf("parse success statuses: %v", err)
+	}
+
+	wanted := []LabelValue{
+		{Value: "200", Weight: 8},
+		{Value: "201", Weight: 1},
+		{Value: "301", Weight: 2},
+	}
+
+	if diff := cmp.Diff(statuses, wanted); diff != "" {
+		t.Fatalf("invalid success statuses:\n%s", diff)
+	}
+}
+
+func TestParseSuccessStatusesError(t *testing.T) {
+	for _, value := range []string{"boom", "404", "199", "200:0", "200,200", "0200"} {
+		if _, err := ParseSuccessStatuses(value); err == nil {
+			t.Fatalf("no error returned for %q", value)
+		}
+	}
+}
+
+func TestGeneratorSuccessStatuses(t *testing.T) {
+	const requests = 1000
+
+	counter := newTestRequests()
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: newTestDuration(),
+		Errors:   newTestErrors(),
+		Requests: counter,
+		SuccessStatuses: []LabelValue{
+			{Value: "200", Weight: 2},
+			{Value: "204", Weight: 1},
+			{Value: "301", Weight: 1},
+		},
+	}
+
+	for i := 0; i < requests; i++ {
+		generator.simulateRequest(0)
+	}
+
+	count := func(status, code string) int {
+		return int(testutil.ToFloat64(counter.With(prometheus.Labels{
+			EndpointLabel: defaultEndpoint,
+			MethodLabel:   defaultMethod,
+			StatusLabel:   status,
+			CodeLabel:     code,
+		})))
+	}
+
+	var (
+		ok         = count(statusClassSuccess, "200")
+		noContent  = count(statusClassSuccess, "204")
+		redirect   = count(statusClassRedirect, "301")
+		successful = ok + noContent + redirect
+	)
+
+	// The test configuration doesn't fail any request, and only the
+	// configured status codes are used.
+	if got := testutil.CollectAndCount(counter); got != 3 {
+		t.Fatalf("invalid number of series: %d", got)
+	}
+
+	if successful != requests {
+		t.Fatalf("invalid number of successful requests: %d", successful)
+	}
+
+	if ratio := float64(ok) / float64(successful); ratio < 0.45 || ratio > 0.55 {
+		t.Fatalf("invalid ratio of 200 responses: %v", ratio)
+	}
+
+	if ratio := float64(redirect) / float64(successful); ratio < 0.2 || ratio > 0.3 {
+		t.Fatalf("invalid ratio of 301 responses: %v", ratio)
+	}
+}
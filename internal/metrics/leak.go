@@ -0,0 +1,25 @@
+package metrics
+
+import "time"
+
+// MemoryLeak simulates the memory usage of a leaking process. The usage grows
+// linearly from 0 to 1 over Period, then drops to 0, simulating a restart, and
+// starts growing again.
+type MemoryLeak struct {
+	// Period is how long the usage takes to grow from 0 to 1. If Period is
+	// zero, the simulation is disabled.
+	Period time.Duration
+
+	// Usage is set to the simulated memory usage, as a ratio between 0 and 1.
+	Usage Gauge
+}
+
+func (l MemoryLeak) enabled() bool {
+	return l.Period > 0
+}
+
+// usage returns the memory usage after the given time has elapsed since the
+// Generator started.
+func (l MemoryLeak) usage(elapsed time.Duration) float64 {
+	return float64(elapsed%l.Period) / float64(l.Period)
+}
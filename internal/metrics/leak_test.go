@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMemoryLeakUsage(t *testing.T) {
+	leak := MemoryLeak{
+		Period: 4 * time.Second,
+	}
+
+	var usages []float64
+
+	for elapsed := time.Duration(0); elapsed < 10*time.Second; elapsed += time.Second {
+		usages = append(usages, leak.usage(elapsed))
+	}
+
+	wanted := []float64{0, 0.25, 0.5, 0.75, 0, 0.25, 0.5, 0.75, 0, 0.25}
+
+	if diff := cmp.Diff(usages, wanted); diff != "" {
+		t.Fatalf("invalid usages:\n%s", diff)
+	}
+}
+
+func TestGeneratorMemoryLeak(t *testing.T) {
+	var usage mockGauge
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: newTestDuration(),
+		Errors:   newTestErrors(),
+		MemoryLeak: MemoryLeak{
+			Period: 2 * time.Second,
+			Usage:  &usage,
+		},
+	}
+
+	runWithFakeClock(t, &generator, 4)
+
+	wanted := []float64{0, 0.5, 0, 0.5, 0}
+
+	if diff := cmp.Diff(usage.values, wanted); diff != "" {
+		t.Fatalf("invalid usages:\n%s", diff)
+	}
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import "time"
+
+// tokenBucket limits the rate of events. The bucket holds up to one second
+// worth of tokens, and at least one token. Every event takes a token, and
+// tokens are added back at the given rate.
+type tokenBucket struct {
+	rate    float64
+	tokens  float64
+	last    time.Duration
+	started bool
+}
+
+func (b *tokenBucket) capacity() float64 {
+	if b.rate < 1 {
+		return 1
+	}
+
+	return b.rate
+}
+
+// allow reports whether an event is allowed after the given time has elapsed
+// since the Generator started, taking a token if it is.
+func (b *tokenBucket) allow(elapsed time.Duration) bool {
+	if !b.started {
+		b.started = true
+		b.tokens = b.capacity()
+	} else if elapsed > b.last {
+		b.tokens += (elapsed - b.last).Seconds() * b.rate
+
+		if b.tokens > b.capacity() {
+			b.tokens = b.capacity()
+		}
+	}
+
+	b.last = elapsed
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
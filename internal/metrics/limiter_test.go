@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := tokenBucket{
+		rate: 2,
+	}
+
+	var allowed int
+
+	// Ten events per second for ten seconds.
+	for elapsed := time.Duration(0); elapsed < 10*time.Second; elapsed += 100 * time.Millisecond {
+		if bucket.allow(elapsed) {
+			allowed++
+		}
+	}
+
+	// Two events are allowed by the initial tokens, then two per second.
+	if allowed < 20 || allowed > 22 {
+		t.Fatalf("invalid number of allowed events: %d", allowed)
+	}
+}
+
+func TestTokenBucketSlowRate(t *testing.T) {
+	bucket := tokenBucket{
+		rate: 0.5,
+	}
+
+	var allowed []bool
+
+	for elapsed := time.Duration(0); elapsed < 5*time.Second; elapsed += time.Second {
+		allowed = append(allowed, bucket.allow(elapsed))
+	}
+
+	for i, want := range []bool{true, false, true, false, true} {
+		if allowed[i] != want {
+			t.Fatalf("invalid event %d: wanted %v, got %v", i, want, allowed[i])
+		}
+	}
+}
+
+func TestGeneratorMaxObservationRate(t *testing.T) {
+	const requests = 100
+
+	var (
+		duration = newTestDuration()
+		dropped  = prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+	)
+
+	generator := Generator{
+		Config:              newTestConfig(t),
+		Duration:            duration,
+		Errors:              newTestErrors(),
+		MaxObservationRate:  2,
+		DroppedObservations: dropped,
+	}
+
+	for i := 0; i < requests; i++ {
+		generator.simulateRequest(time.Duration(i) * 100 * time.Millisecond)
+	}
+
+	observed := histogramCount(t, duration, prometheus.Labels{})
+
+	if observed < 20 || observed > 22 {
+		t.Fatalf("invalid number of observations: %d", observed)
+	}
+
+	if got := int(testutil.ToFloat64(dropped)); got != requests-observed {
+		t.Fatalf("invalid number of dropped observations: wanted %d, got %d", requests-observed, got)
+	}
+}
@@ -2,36 +2,326 @@ package metrics
 
 import (
 	"context"
+	"log/slog"
+	"math"
 	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/francescomari/metrics-generator/internal/limits"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Histogram interface {
-	Observe(float64)
+// defaultRequestRate is the number of requests per second simulated by the
+// Generator if no RateSource is specified.
+const defaultRequestRate = 1
+
+// overloadThreshold is the utilization of the capacity past which requests
+// start failing more often.
+const overloadThreshold = 0.8
+
+// Names of the labels attached to the metrics of the simulated requests.
+const (
+	TenantLabel      = "tenant"
+	BuildIDLabel     = "build_id"
+	StatusClassLabel = "status_class"
+	EndpointLabel    = "endpoint"
+	MethodLabel      = "method"
+	StatusLabel      = "status"
+	CodeLabel        = "code"
+	RouteLabel       = "route"
+)
+
+// Default endpoint and method of the simulated requests, used when the
+// Generator doesn't specify any.
+const (
+	defaultEndpoint = "/"
+	defaultMethod   = "GET"
+)
+
+// maxRequestsCardinality is the number of series of the Requests counter past
+// which the Generator logs a warning.
+const maxRequestsCardinality = 1000
+
+// Status classes used as values for the status class label of the Errors
+// counter and for the status label of the Requests counter.
+const (
+	statusClassSuccess     = "2xx"
+	statusClassRedirect    = "3xx"
+	statusClassClientError = "4xx"
+	statusClassServerError = "5xx"
+)
+
+// Status codes used as values for the code label of the Requests counter. The
+// status codes of the failed requests are used only if the configuration
+// doesn't specify a status distribution.
+const (
+	statusCodeSuccess     = "200"
+	statusCodeClientError = "400"
+	statusCodeServerError = "500"
+)
+
+// HistogramVec is a histogram partitioned by labels. A summary partitioned by
+// labels is a HistogramVec, too.
+type HistogramVec interface {
+	GetMetricWithLabelValues(lvs ...string) (prometheus.Observer, error)
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec interface {
+	GetMetricWithLabelValues(lvs ...string) (prometheus.Counter, error)
 }
 
 type Counter interface {
 	Inc()
 }
 
+type Gauge interface {
+	Inc()
+	Dec()
+	Set(float64)
+}
+
+// Sampler returns the duration of a simulated request, in seconds.
+type Sampler interface {
+	Sample() float64
+}
+
 type Generator struct {
-	Config   *limits.Config
-	Duration Histogram
-	Errors   Counter
+	Config *limits.Config
+
+	// Duration and Errors are partitioned by the labels returned by
+	// LabelNames, in that order. Errors is additionally partitioned by
+	// StatusClassLabel, which comes last.
+	Duration HistogramVec
+	Errors   CounterVec
+
+	// Requests, if not nil, counts every simulated request. It is partitioned
+	// by the labels returned by RequestsLabelNames, in that order.
+	Requests CounterVec
+
+	// Endpoints and Methods are the values of EndpointLabel and MethodLabel
+	// attached to Requests. Every simulated request picks an endpoint and a
+	// method at random. If empty, every request uses the "/" endpoint and the
+	// GET method.
+	Endpoints []string
+	Methods   []string
+
+	// CacheObservers, if true, caches the observers and counters resolved
+	// from Duration and Errors for every combination of label values, instead
+	// of resolving them for every simulated request.
+	CacheObservers bool
+
+	// MaxObservationRate, if greater than zero, is the maximum number of
+	// observations per second recorded in Duration. Excess observations are
+	// dropped and counted in DroppedObservations, if not nil.
+	MaxObservationRate  float64
+	DroppedObservations Counter
+
+	// ObservationErrors, if not nil, is incremented every time an observation
+	// can't be recorded in Duration or Errors.
+	ObservationErrors Counter
+
+	// InFlight, if not nil, is incremented when a simulated request starts and
+	// decremented when it completes, after its duration elapsed.
+	InFlight Gauge
+
+	// SeenSeries, if not nil, is set to the number of distinct combinations
+	// of label values observed into Duration since the Generator started, to
+	// make the cardinality of the metrics visible. The series are never
+	// forgotten, so SeenSeries never decreases.
+	SeenSeries Gauge
+
+	// Connections, if not nil, is set to the number of simulated connections
+	// serving the requests, which is the current rate divided by
+	// ConnectionReuse. If ConnectionReuse is not greater than zero, every
+	// connection serves one request per second.
+	Connections     Gauge
+	ConnectionReuse float64
+
+	// Heartbeat, if not nil, is set to the current Unix time in seconds at
+	// every iteration of the Generator.
+	Heartbeat Gauge
+
+	// Sampler is the source of the durations of the simulated requests. If
+	// Sampler is nil, a UniformSampler reading from Config is used.
+	Sampler Sampler
+
+	// FastErrorDuration, if greater than zero, is the duration observed for
+	// failed requests instead of a duration from Sampler. This simulates a
+	// service that fails fast.
+	FastErrorDuration float64
+
+	// DeterministicErrors, if true, fails requests at regular intervals
+	// instead of at random, so that the fraction of failed requests exactly
+	// matches the errors percentage. For example, with an errors percentage of
+	// 10, every tenth request fails.
+	DeterministicErrors bool
+
+	// ErrorBurst, if enabled, periodically raises the errors percentage for a
+	// short time.
+	ErrorBurst ErrorBurst
+
+	// Rate is the source of the rate of the simulated requests. If Rate is
+	// nil, the Generator uses the request rate of Config, like ConfigRate.
+	Rate RateSource
+
+	// RateHistory, if not nil, samples the rate of the simulated requests at
+	// regular intervals.
+	RateHistory *RateHistory
+
+	// Readiness, if not nil, is ready after Run simulates its first request,
+	// until Run returns.
+	Readiness *Readiness
+
+	// Tenants, if not empty, are assigned to the simulated requests according
+	// to their weight. The name of the tenant is attached to the metrics of the
+	// request in the TenantLabel label.
+	Tenants []Tenant
+
+	// Churn, if enabled, periodically changes the value of the BuildIDLabel
+	// label attached to the metrics of the simulated requests.
+	Churn Churn
+
+	// BucketGuarantee, if enabled, replaces the durations of some of the
+	// observations recorded in Duration, so that every bucket of Duration
+	// receives at least one observation per cycle.
+	BucketGuarantee BucketGuarantee
+
+	// Staleness, if enabled, periodically stops updating one series for a
+	// while, to simulate a target that stops reporting.
+	Staleness Staleness
+
+	// MemoryLeak, if enabled, sets its gauge to the memory usage of a
+	// simulated leaking process.
+	MemoryLeak MemoryLeak
+
+	// SuccessStatuses, if not empty, are the status codes assigned to the
+	// successful requests according to their weight, attached to Requests in
+	// the CodeLabel label. If empty, every successful request has status code
+	// 200.
+	SuccessStatuses []LabelValue
+
+	// Routes, if not empty, are assigned to the simulated requests according
+	// to their weight. The route is attached to the metrics of the request in
+	// the RouteLabel label.
+	Routes []LabelValue
+
+	// Labels are custom labels attached to the metrics of the simulated
+	// requests. Every simulated request picks a value for every label.
+	Labels []Label
+
+	// SLO, if not nil, computes the compliance of the recent requests with a
+	// latency objective. The compliance ratio is set on SLOCompliance.
+	SLO           *SLO
+	SLOCompliance Gauge
+
+	// MaxObservations, if greater than zero, is the number of observations
+	// recorded in Duration after which Run stops simulating requests and
+	// returns. Requests whose observation is dropped don't count.
+	MaxObservations uint64
+
+	// Rand is the source of randomness of the Generator. If Rand is nil, a
+	// source seeded with the current time is used.
+	Rand *rand.Rand
+
+	// Logger is used to log warnings and errors. If Logger is nil, the default
+	// logger of the slog package is used.
+	Logger *slog.Logger
+
+	// Clock is used to tell the time and to wait between simulated requests.
+	// If Clock is nil, the system clock is used.
+	Clock Clock
+
+	labelValues   []string
+	choosers      *choosers
+	statusChooser statusChooser
+	requests      uint64
+	observations  uint64
+	defaultRand   *rand.Rand
+	cache         observerCache
+	requestsCache observerCache
+	series        seriesSet
+	stale         staleSeries
+	limiter       tokenBucket
+}
+
+// LabelNames returns the names of the labels attached to the metrics of every
+// simulated request.
+func (g *Generator) LabelNames() []string {
+	var names []string
+
+	if len(g.Tenants) > 0 {
+		names = append(names, TenantLabel)
+	}
+
+	if g.Churn.enabled() {
+		names = append(names, BuildIDLabel)
+	}
+
+	if len(g.Routes) > 0 {
+		names = append(names, RouteLabel)
+	}
+
+	for _, label := range g.Labels {
+		names = append(names, label.Name)
+	}
+
+	return names
+}
+
+// RequestsLabelNames returns the names of the labels attached to Requests.
+func (g *Generator) RequestsLabelNames() []string {
+	return append(g.LabelNames(), EndpointLabel, MethodLabel, StatusLabel, CodeLabel)
 }
 
 func (g *Generator) Run(ctx context.Context) error {
+	if cardinality := g.requestsCardinality(); cardinality > maxRequestsCardinality {
+		g.logger().Warn("the requests counter has a high cardinality", "max_series", cardinality)
+	}
+
+	clock := g.clock()
+	start := clock.Now()
+
+	var ready bool
+
+	if g.Readiness != nil {
+		defer g.Readiness.set(false)
+	}
+
+	// next is when the next request is due. Waiting until next, instead of
+	// waiting for a full interval after every request, prevents the time spent
+	// simulating the requests from lowering the rate.
+	next := start
+
 	for {
-		g.Duration.Observe(g.randomDuration())
+		if g.MaxObservations > 0 && atomic.LoadUint64(&g.observations) >= g.MaxObservations {
+			return nil
+		}
+
+		now := clock.Now()
+
+		if g.Heartbeat != nil {
+			g.Heartbeat.Set(unixSeconds(now))
+		}
+
+		next = next.Add(g.simulateRequest(now.Sub(start)))
 
-		if g.shouldFailRequest() {
-			g.Errors.Inc()
+		if g.Readiness != nil && !ready {
+			g.Readiness.set(true)
+			ready = true
+		}
+
+		// If the Generator fell behind, for example because the process was
+		// suspended, don't send a burst of requests to catch up.
+		if now = clock.Now(); next.Before(now) {
+			next = now
 		}
 
 		select {
-		case <-time.After(1 * time.Second):
+		case <-clock.After(next.Sub(now)):
 			continue
 		case <-ctx.Done():
 			return ctx.Err()
@@ -39,14 +329,483 @@ func (g *Generator) Run(ctx context.Context) error {
 	}
 }
 
-func (g *Generator) shouldFailRequest() bool {
-	return rand.Intn(100) < g.Config.ErrorsPercentage()
+func (g *Generator) rate(elapsed time.Duration, settings limits.Settings) float64 {
+	if g.Rate != nil {
+		return g.Rate.Rate(elapsed)
+	}
+
+	if settings.RequestRate > 0 {
+		return float64(settings.RequestRate)
+	}
+
+	return defaultRequestRate
+}
+
+// connections returns the number of simulated connections serving requests at
+// the given rate.
+func (g *Generator) connections(rate float64) float64 {
+	if g.ConnectionReuse > 0 {
+		return rate / g.ConnectionReuse
+	}
+
+	return rate
+}
+
+func (g *Generator) random() *rand.Rand {
+	if g.Rand != nil {
+		return g.Rand
+	}
+
+	if g.defaultRand == nil {
+		g.defaultRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return g.defaultRand
+}
+
+func (g *Generator) logger() *slog.Logger {
+	if g.Logger == nil {
+		return slog.Default()
+	}
+
+	return g.Logger
 }
 
-func (g *Generator) randomDuration() float64 {
-	return float64(randomNumberBetween(g.Config.DurationInterval()))
+func (g *Generator) clock() Clock {
+	if g.Clock == nil {
+		return realClock{}
+	}
+
+	return g.Clock
 }
 
-func randomNumberBetween(min, max int) int {
-	return min + rand.Intn(max-min+1)
+// simulateRequest simulates a request after the given time has elapsed since
+// the Generator started, and returns how long to wait before simulating the
+// next one.
+func (g *Generator) simulateRequest(elapsed time.Duration) time.Duration {
+	// The configuration is read once per request, to avoid acquiring its lock
+	// multiple times.
+	settings := g.Config.Snapshot()
+	rate := g.rate(elapsed, settings)
+
+	if g.RateHistory != nil {
+		g.RateHistory.record(elapsed, rate)
+	}
+
+	if g.Connections != nil {
+		g.Connections.Set(g.connections(rate))
+	}
+
+	if g.MemoryLeak.enabled() {
+		g.MemoryLeak.Usage.Set(g.MemoryLeak.usage(elapsed))
+	}
+
+	// The label values are appended in the same order as the label names
+	// returned by LabelNames. The slice is reused across requests to avoid
+	// allocations.
+	labelValues := g.labelValues[:0]
+
+	tenant := g.pickTenant()
+
+	if tenant != nil {
+		labelValues = append(labelValues, tenant.Name)
+	}
+
+	if g.Churn.enabled() {
+		labelValues = append(labelValues, g.Churn.value(elapsed))
+	}
+
+	if len(g.Routes) > 0 {
+		labelValues = append(labelValues, g.pickRoute())
+	}
+
+	for i := range g.Labels {
+		labelValues = append(labelValues, g.pickLabelValue(i))
+	}
+
+	g.labelValues = labelValues
+
+	failed := g.shouldFailRequest(elapsed, tenant, settings, rate)
+	duration := g.requestDuration(failed, settings)
+	stale := g.isStale(elapsed, labelValues)
+	observe := !stale && g.allowObservation(elapsed)
+
+	if observe && g.BucketGuarantee.enabled() {
+		if guaranteed, ok := g.BucketGuarantee.duration(); ok {
+			duration = guaranteed
+		}
+	}
+
+	if observe {
+		if observer, err := g.durationObserver(labelValues); err != nil {
+			g.observationError(err)
+		} else {
+			observer.Observe(duration)
+			atomic.AddUint64(&g.observations, 1)
+		}
+	}
+
+	if g.SeenSeries != nil {
+		g.SeenSeries.Set(float64(g.series.add(labelValues)))
+	}
+
+	status, code := g.successStatus()
+
+	if failed {
+		status, code = g.errorStatus(settings)
+	}
+
+	if failed && !stale {
+		if counter, err := g.errorsCounter(append(labelValues, status)); err != nil {
+			g.observationError(err)
+		} else {
+			counter.Inc()
+		}
+	}
+
+	if g.Requests != nil && !stale {
+		if counter, err := g.requestsCounter(append(labelValues, g.pickEndpoint(), g.pickMethod(), status, code)); err != nil {
+			g.observationError(err)
+		} else {
+			counter.Inc()
+		}
+	}
+
+	if g.SLO != nil {
+		g.SLOCompliance.Set(g.SLO.record(duration, failed))
+	}
+
+	if g.InFlight != nil {
+		g.InFlight.Inc()
+		g.clock().AfterFunc(seconds(duration), g.InFlight.Dec)
+	}
+
+	return rateInterval(rate)
+}
+
+func (g *Generator) durationObserver(labelValues []string) (prometheus.Observer, error) {
+	if g.CacheObservers {
+		return g.cache.observer(g.Duration, labelValues)
+	}
+
+	return g.Duration.GetMetricWithLabelValues(labelValues...)
+}
+
+func (g *Generator) errorsCounter(labelValues []string) (prometheus.Counter, error) {
+	if g.CacheObservers {
+		return g.cache.counter(g.Errors, labelValues)
+	}
+
+	return g.Errors.GetMetricWithLabelValues(labelValues...)
+}
+
+func (g *Generator) requestsCounter(labelValues []string) (prometheus.Counter, error) {
+	if g.CacheObservers {
+		return g.requestsCache.counter(g.Requests, labelValues)
+	}
+
+	return g.Requests.GetMetricWithLabelValues(labelValues...)
+}
+
+// isStale reports whether the series with the given label values must not be
+// updated because of a gap of Staleness.
+func (g *Generator) isStale(elapsed time.Duration, labelValues []string) bool {
+	gap, ok := g.Staleness.gap(elapsed)
+	if !ok {
+		return false
+	}
+
+	return g.stale.stale(gap, labelValues)
+}
+
+// allowObservation reports whether an observation can be recorded in Duration
+// without exceeding MaxObservationRate.
+func (g *Generator) allowObservation(elapsed time.Duration) bool {
+	if g.MaxObservationRate <= 0 {
+		return true
+	}
+
+	g.limiter.rate = g.MaxObservationRate
+
+	if g.limiter.allow(elapsed) {
+		return true
+	}
+
+	if g.DroppedObservations != nil {
+		g.DroppedObservations.Inc()
+	}
+
+	return false
+}
+
+func (g *Generator) observationError(err error) {
+	g.logger().Debug("record observation", "err", err)
+
+	if g.ObservationErrors != nil {
+		g.ObservationErrors.Inc()
+	}
+}
+
+// choosers pick the tenants, the routes and the values of the custom labels of
+// the simulated requests.
+type choosers struct {
+	tenants         weightedChooser
+	routes          weightedChooser
+	labels          []weightedChooser
+	successStatuses weightedChooser
+	endpoints       weightedChooser
+	methods         weightedChooser
+}
+
+// statusChooser picks the status codes of the failed requests. It is rebuilt
+// every time the version of the status distribution of the configuration
+// changes.
+type statusChooser struct {
+	version uint64
+	chooser weightedChooser
+}
+
+// getChoosers returns the choosers, building them on first use.
+func (g *Generator) getChoosers() *choosers {
+	if g.choosers != nil {
+		return g.choosers
+	}
+
+	c := choosers{
+		tenants:         tenantsChooser(g.Tenants),
+		routes:          labelValuesChooser(g.Routes),
+		successStatuses: labelValuesChooser(g.SuccessStatuses),
+		endpoints:       uniformChooser(len(g.Endpoints)),
+		methods:         uniformChooser(len(g.Methods)),
+	}
+
+	for _, label := range g.Labels {
+		c.labels = append(c.labels, labelValuesChooser(label.Values))
+	}
+
+	g.choosers = &c
+
+	return g.choosers
+}
+
+func (g *Generator) pickTenant() *Tenant {
+	if len(g.Tenants) == 0 {
+		return nil
+	}
+
+	return &g.Tenants[g.getChoosers().tenants.choose(g.random())]
+}
+
+func (g *Generator) pickRoute() string {
+	return g.Routes[g.getChoosers().routes.choose(g.random())].Value
+}
+
+func (g *Generator) pickLabelValue(i int) string {
+	return g.Labels[i].Values[g.getChoosers().labels[i].choose(g.random())].Value
+}
+
+// successStatus returns the status class and the status code of a successful
+// request.
+func (g *Generator) successStatus() (string, string) {
+	if len(g.SuccessStatuses) == 0 {
+		return statusClassSuccess, statusCodeSuccess
+	}
+
+	code := g.SuccessStatuses[g.getChoosers().successStatuses.choose(g.random())].Value
+
+	if strings.HasPrefix(code, "3") {
+		return statusClassRedirect, code
+	}
+
+	return statusClassSuccess, code
+}
+
+// pickStatusCode picks a status code from a non-empty status distribution,
+// whose version is the one in the same Settings.
+func (g *Generator) pickStatusCode(distribution limits.StatusDistribution, version uint64) int {
+	// A non-empty distribution never has version zero, so the zero value of
+	// statusChooser is always rebuilt.
+	if g.statusChooser.version != version {
+		weights := make([]int, len(distribution))
+
+		for i, w := range distribution {
+			weights[i] = w.Weight
+		}
+
+		g.statusChooser = statusChooser{
+			version: version,
+			chooser: newWeightedChooser(weights),
+		}
+	}
+
+	return distribution[g.statusChooser.chooser.choose(g.random())].Code
+}
+
+func (g *Generator) pickEndpoint() string {
+	if len(g.Endpoints) == 0 {
+		return defaultEndpoint
+	}
+
+	return g.Endpoints[g.getChoosers().endpoints.choose(g.random())]
+}
+
+func (g *Generator) pickMethod() string {
+	if len(g.Methods) == 0 {
+		return defaultMethod
+	}
+
+	return g.Methods[g.getChoosers().methods.choose(g.random())]
+}
+
+// requestsCardinality returns the maximum number of series of Requests.
+func (g *Generator) requestsCardinality() int {
+	// Every failed request has one of two status classes, and every
+	// successful request one of the success statuses.
+	cardinality := (2 + atLeastOne(len(g.SuccessStatuses))) * atLeastOne(len(g.Endpoints)) * atLeastOne(len(g.Methods)) * atLeastOne(len(g.Tenants))
+
+	if g.Churn.enabled() {
+		cardinality *= g.Churn.MaxValues
+	}
+
+	cardinality *= atLeastOne(len(g.Routes))
+
+	for _, label := range g.Labels {
+		cardinality *= len(label.Values)
+	}
+
+	return cardinality
+}
+
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+
+	return n
+}
+
+func (g *Generator) shouldFailRequest(elapsed time.Duration, tenant *Tenant, settings limits.Settings, rate float64) bool {
+	percentage := g.errorsPercentage(elapsed, tenant, settings, rate)
+
+	if g.DeterministicErrors {
+		// A request fails every time the number of requests times the
+		// percentage crosses a multiple of 100.
+		g.requests++
+		return g.requests*uint64(percentage)/100 > (g.requests-1)*uint64(percentage)/100
+	}
+
+	return g.random().Intn(100) < percentage
+}
+
+func (g *Generator) errorsPercentage(elapsed time.Duration, tenant *Tenant, settings limits.Settings, rate float64) int {
+	base := settings.ErrorsPercentage
+
+	if tenant != nil && tenant.ErrorsPercentage != nil {
+		base = *tenant.ErrorsPercentage
+	}
+
+	base = g.ErrorBurst.errorsPercentage(elapsed, base)
+
+	return overloadedErrorsPercentage(base, rate, settings.Capacity)
+}
+
+// errorStatus returns the status class and the status code of a failed
+// request. If the configuration specifies a status distribution, the status
+// code is picked according to it. Otherwise, the status class is picked
+// according to the client error ratio.
+func (g *Generator) errorStatus(settings limits.Settings) (string, string) {
+	if distribution := settings.StatusDistribution; len(distribution) > 0 {
+		code := g.pickStatusCode(distribution, settings.StatusDistributionVersion)
+		return statusClassOf(code), strconv.Itoa(code)
+	}
+
+	if class := g.errorStatusClass(settings); class == statusClassClientError {
+		return class, statusCodeClientError
+	}
+
+	return statusClassServerError, statusCodeServerError
+}
+
+func statusClassOf(code int) string {
+	if code < 500 {
+		return statusClassClientError
+	}
+
+	return statusClassServerError
+}
+
+func (g *Generator) errorStatusClass(settings limits.Settings) string {
+	if g.random().Float64() < settings.ClientErrorRatio {
+		return statusClassClientError
+	}
+
+	return statusClassServerError
+}
+
+// requestDuration returns the duration of a request, multiplied by the
+// duration scale of the configuration. If the configuration doesn't specify a
+// duration scale, the duration is not scaled.
+func (g *Generator) requestDuration(failed bool, settings limits.Settings) float64 {
+	var duration float64
+
+	if failed && g.FastErrorDuration > 0 {
+		duration = g.FastErrorDuration
+	} else {
+		duration = g.sampleDuration(settings)
+	}
+
+	if settings.DurationScale > 0 {
+		duration *= settings.DurationScale
+	}
+
+	return duration
+}
+
+func (g *Generator) sampleDuration(settings limits.Settings) float64 {
+	if g.Sampler != nil {
+		return g.Sampler.Sample()
+	}
+
+	// Equivalent to a UniformSampler reading from Config, without reading
+	// Config again.
+	return randomDurationBetween(g.random(), settings.MinDuration, settings.MaxDuration)
+}
+
+// rateInterval returns the interval between two requests arriving at the given
+// rate, in requests per second.
+func rateInterval(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// overloadedErrorsPercentage returns the percentage of failed requests for a
+// service receiving requests at the given rate. If the capacity is zero, or if
+// the utilization of the capacity is below the overload threshold, the base
+// percentage is returned. Past the threshold, the percentage grows linearly
+// until it reaches 100% when the rate matches the capacity.
+func overloadedErrorsPercentage(base int, rate float64, capacity int) int {
+	if capacity <= 0 {
+		return base
+	}
+
+	utilization := rate / float64(capacity)
+
+	if utilization <= overloadThreshold {
+		return base
+	}
+
+	if utilization >= 1 {
+		return 100
+	}
+
+	overload := (utilization - overloadThreshold) / (1 - overloadThreshold)
+
+	return base + int(math.Round(float64(100-base)*overload))
 }
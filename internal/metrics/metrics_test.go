@@ -0,0 +1,1001 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fakeClock moves forward in time as soon as somebody waits on it. After the
+// given number of ticks, fakeClock cancels the context and stops moving.
+// Functions scheduled with AfterFunc are called synchronously when fakeClock
+// moves past their deadline.
+type fakeClock struct {
+	now    time.Time
+	ticks  int
+	cancel context.CancelFunc
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	f        func()
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) {
+	c.timers = append(c.timers, fakeTimer{deadline: c.now.Add(d), f: f})
+}
+
+func (c *fakeClock) fireTimers() {
+	var pending []fakeTimer
+
+	for _, timer := range c.timers {
+		if timer.deadline.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.f()
+		}
+	}
+
+	c.timers = pending
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+
+	if c.ticks == 0 {
+		c.cancel()
+		return ch
+	}
+
+	c.ticks--
+	c.now = c.now.Add(d)
+	c.fireTimers()
+	ch <- c.now
+
+	return ch
+}
+
+// mockGauge records the values it is set to. Increments and decrements are
+// applied to value.
+type mockGauge struct {
+	values []float64
+	value  float64
+}
+
+func (g *mockGauge) Inc() {
+	g.value++
+}
+
+func (g *mockGauge) Dec() {
+	g.value--
+}
+
+func (g *mockGauge) Set(value float64) {
+	g.values = append(g.values, value)
+}
+
+type mockCounter struct {
+	value int
+}
+
+func (c *mockCounter) Inc() {
+	c.value++
+}
+
+type failingHistogramVec struct{}
+
+func (failingHistogramVec) GetMetricWithLabelValues(...string) (prometheus.Observer, error) {
+	return nil, errors.New("boom")
+}
+
+type failingCounterVec struct{}
+
+func (failingCounterVec) GetMetricWithLabelValues(...string) (prometheus.Counter, error) {
+	return nil, errors.New("boom")
+}
+
+// recordingHistogramVec records the observations of all its observers.
+type recordingHistogramVec struct {
+	observations []float64
+}
+
+func (v *recordingHistogramVec) GetMetricWithLabelValues(...string) (prometheus.Observer, error) {
+	return prometheus.ObserverFunc(func(value float64) {
+		v.observations = append(v.observations, value)
+	}), nil
+}
+
+func newTestConfig(t *testing.T) *limits.Config {
+	t.Helper()
+
+	var config limits.Config
+
+	if err := config.SetDurationInterval(1, 10); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	return &config
+}
+
+func newTestDuration(labels ...string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "duration"}, labels)
+}
+
+func newTestRequests(labels ...string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests"}, append(labels, EndpointLabel, MethodLabel, StatusLabel, CodeLabel))
+}
+
+// defaultStatuses are the status classes and codes of the simulated requests
+// if the configuration doesn't specify a status distribution.
+var defaultStatuses = []struct {
+	class string
+	code  string
+}{
+	{statusClassSuccess, statusCodeSuccess},
+	{statusClassClientError, statusCodeClientError},
+	{statusClassServerError, statusCodeServerError},
+}
+
+func newTestErrors(labels ...string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "errors"}, append(labels, StatusClassLabel))
+}
+
+func writeHistogram(t *testing.T, vec *prometheus.HistogramVec, labels prometheus.Labels) *dto.Histogram {
+	t.Helper()
+
+	histogram, ok := vec.With(labels).(prometheus.Metric)
+	if !ok {
+		t.Fatalf("observer is not a metric")
+	}
+
+	var m dto.Metric
+
+	if err := histogram.Write(&m); err != nil {
+		t.Fatalf("write metric: %v", err)
+	}
+
+	return m.GetHistogram()
+}
+
+func histogramCount(t *testing.T, vec *prometheus.HistogramVec, labels prometheus.Labels) int {
+	t.Helper()
+	return int(writeHistogram(t, vec, labels).GetSampleCount())
+}
+
+func histogramSum(t *testing.T, vec *prometheus.HistogramVec, labels prometheus.Labels) float64 {
+	t.Helper()
+	return writeHistogram(t, vec, labels).GetSampleSum()
+}
+
+func runWithFakeClock(t *testing.T, generator *Generator, ticks int) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	generator.Clock = &fakeClock{
+		now:    time.Unix(1000, 0),
+		ticks:  ticks,
+		cancel: cancel,
+	}
+
+	if err := generator.Run(ctx); err != context.Canceled {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestGeneratorHeartbeat(t *testing.T) {
+	var heartbeat mockGauge
+
+	generator := Generator{
+		Config:    newTestConfig(t),
+		Duration:  newTestDuration(),
+		Errors:    newTestErrors(),
+		Heartbeat: &heartbeat,
+	}
+
+	runWithFakeClock(t, &generator, 3)
+
+	wanted := []float64{1000, 1001, 1002, 1003}
+
+	if diff := cmp.Diff(heartbeat.values, wanted); diff != "" {
+		t.Fatalf("invalid heartbeat values:\n%s", diff)
+	}
+}
+
+func TestGeneratorConnections(t *testing.T) {
+	var connections mockGauge
+
+	generator := Generator{
+		Config:      newTestConfig(t),
+		Duration:    newTestDuration(),
+		Errors:      newTestErrors(),
+		Connections: &connections,
+		Rate: StepRate{
+			Steps: []Step{
+				{Rate: 10, Duration: time.Second},
+				{Rate: 40, Duration: time.Second},
+			},
+		},
+		ConnectionReuse: 4,
+	}
+
+	generator.simulateRequest(0)
+	generator.simulateRequest(time.Second)
+
+	if diff := cmp.Diff(connections.values, []float64{2.5, 10}); diff != "" {
+		t.Fatalf("invalid connections:\n%s", diff)
+	}
+}
+
+func TestOverloadedErrorsPercentageNoCapacity(t *testing.T) {
+	for _, rate := range []float64{1, 10, 100, 1000} {
+		if got := overloadedErrorsPercentage(10, rate, 0); got != 10 {
+			t.Fatalf("invalid errors percentage for rate %v: wanted 10, got %d", rate, got)
+		}
+	}
+}
+
+func TestOverloadedErrorsPercentageBelowThreshold(t *testing.T) {
+	for _, rate := range []float64{0, 10, 50, 80} {
+		if got := overloadedErrorsPercentage(10, rate, 100); got != 10 {
+			t.Fatalf("invalid errors percentage for rate %v: wanted 10, got %d", rate, got)
+		}
+	}
+}
+
+func TestOverloadedErrorsPercentageAboveThreshold(t *testing.T) {
+	tests := []struct {
+		rate   float64
+		wanted int
+	}{
+		{rate: 85, wanted: 40},
+		{rate: 90, wanted: 60},
+		{rate: 95, wanted: 80},
+		{rate: 100, wanted: 100},
+		{rate: 200, wanted: 100},
+	}
+
+	previous := 20
+
+	for _, test := range tests {
+		got := overloadedErrorsPercentage(20, test.rate, 100)
+
+		if got != test.wanted {
+			t.Fatalf("invalid errors percentage for rate %v: wanted %d, got %d", test.rate, test.wanted, got)
+		}
+
+		if got < previous {
+			t.Fatalf("errors percentage decreased for rate %v: from %d to %d", test.rate, previous, got)
+		}
+
+		previous = got
+	}
+}
+
+func TestErrorStatusClass(t *testing.T) {
+	const samples = 10000
+
+	for _, ratio := range []float64{0, 0.25, 0.5, 1} {
+		var config limits.Config
+
+		if err := config.SetClientErrorRatio(ratio); err != nil {
+			t.Fatalf("set client error ratio: %v", err)
+		}
+
+		generator := Generator{
+			Config: &config,
+		}
+
+		var clientErrors, serverErrors int
+
+		for i := 0; i < samples; i++ {
+			switch class := generator.errorStatusClass(config.Snapshot()); class {
+			case statusClassClientError:
+				clientErrors++
+			case statusClassServerError:
+				serverErrors++
+			default:
+				t.Fatalf("invalid status class: %v", class)
+			}
+		}
+
+		if got := float64(clientErrors) / samples; math.Abs(got-ratio) > 0.02 {
+			t.Fatalf("invalid client errors ratio: wanted %v, got %v", ratio, got)
+		}
+
+		if got := float64(serverErrors) / samples; math.Abs(got-(1-ratio)) > 0.02 {
+			t.Fatalf("invalid server errors ratio: wanted %v, got %v", 1-ratio, got)
+		}
+	}
+}
+
+func TestGeneratorObservationErrors(t *testing.T) {
+	for _, cacheObservers := range []bool{false, true} {
+		config := newTestConfig(t)
+
+		if err := config.SetErrorsPercentage(100); err != nil {
+			t.Fatalf("set errors percentage: %v", err)
+		}
+
+		var observationErrors mockCounter
+
+		generator := Generator{
+			Config:            config,
+			Duration:          failingHistogramVec{},
+			Errors:            failingCounterVec{},
+			CacheObservers:    cacheObservers,
+			ObservationErrors: &observationErrors,
+		}
+
+		for i := 0; i < 3; i++ {
+			generator.simulateRequest(0)
+		}
+
+		if observationErrors.value != 6 {
+			t.Fatalf("invalid number of observation errors with cache %v: %d", cacheObservers, observationErrors.value)
+		}
+	}
+}
+
+func TestGeneratorRequests(t *testing.T) {
+	config := newTestConfig(t)
+
+	requests := newTestRequests()
+
+	generator := Generator{
+		Config:    config,
+		Duration:  newTestDuration(),
+		Errors:    newTestErrors(),
+		Requests:  requests,
+		Endpoints: []string{"/users", "/orders"},
+		Methods:   []string{"GET", "POST"},
+	}
+
+	for i := 0; i < 1000; i++ {
+		generator.simulateRequest(0)
+	}
+
+	var total float64
+
+	for _, endpoint := range generator.Endpoints {
+		for _, method := range generator.Methods {
+			for _, status := range defaultStatuses {
+				total += testutil.ToFloat64(requests.WithLabelValues(endpoint, method, status.class, status.code))
+			}
+
+			if testutil.ToFloat64(requests.WithLabelValues(endpoint, method, statusClassSuccess, statusCodeSuccess)) == 0 {
+				t.Fatalf("no requests for %s %s", method, endpoint)
+			}
+		}
+	}
+
+	if total != 1000 {
+		t.Fatalf("invalid number of requests: %v", total)
+	}
+
+	if got := testutil.CollectAndCount(requests); got > 12 {
+		t.Fatalf("invalid number of series: %d", got)
+	}
+}
+
+func TestGeneratorRequestsPerIteration(t *testing.T) {
+	requests := newTestRequests()
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: newTestDuration(),
+		Errors:   newTestErrors(),
+		Requests: requests,
+	}
+
+	runWithFakeClock(t, &generator, 9)
+
+	var total float64
+
+	for _, status := range defaultStatuses {
+		total += testutil.ToFloat64(requests.WithLabelValues(defaultEndpoint, defaultMethod, status.class, status.code))
+	}
+
+	if total != 10 {
+		t.Fatalf("invalid number of requests: %v", total)
+	}
+}
+
+func TestGeneratorMaxObservations(t *testing.T) {
+	duration := newTestDuration()
+
+	generator := Generator{
+		Config:          newTestConfig(t),
+		Duration:        duration,
+		Errors:          newTestErrors(),
+		MaxObservations: 5,
+		Clock: &fakeClock{
+			now:   time.Unix(1000, 0),
+			ticks: 100,
+			cancel: func() {
+				t.Fatalf("context cancelled before reaching the limit")
+			},
+		},
+	}
+
+	if err := generator.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if got := histogramCount(t, duration, prometheus.Labels{}); got != 5 {
+		t.Fatalf("invalid number of observations: %d", got)
+	}
+}
+
+func TestGeneratorMaxObservationsDropped(t *testing.T) {
+	var (
+		duration = newTestDuration()
+		dropped  = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_dropped_total"})
+	)
+
+	generator := Generator{
+		Config:              newTestConfig(t),
+		Duration:            duration,
+		Errors:              newTestErrors(),
+		MaxObservations:     5,
+		MaxObservationRate:  0.5,
+		DroppedObservations: dropped,
+		Clock: &fakeClock{
+			now:   time.Unix(1000, 0),
+			ticks: 100,
+			cancel: func() {
+				t.Fatalf("context cancelled before reaching the limit")
+			},
+		},
+	}
+
+	if err := generator.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// Dropped observations don't count towards the limit.
+	if got := histogramCount(t, duration, prometheus.Labels{}); got != 5 {
+		t.Fatalf("invalid number of observations: %d", got)
+	}
+
+	if got := testutil.ToFloat64(dropped); got == 0 {
+		t.Fatalf("no observations dropped")
+	}
+}
+
+func TestGeneratorRequestsDefaultLabels(t *testing.T) {
+	config := newTestConfig(t)
+
+	if err := config.SetErrorsPercentage(100); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	requests := newTestRequests()
+
+	generator := Generator{
+		Config:   config,
+		Duration: newTestDuration(),
+		Errors:   newTestErrors(),
+		Requests: requests,
+	}
+
+	generator.simulateRequest(0)
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("/", "GET", statusClassServerError, statusCodeServerError)); got != 1 {
+		t.Fatalf("invalid number of requests: %v", got)
+	}
+}
+
+func TestGeneratorRequestsCardinalityWarning(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints int
+		warning   bool
+	}{
+		{name: "below-limit", endpoints: 10, warning: false},
+		{name: "above-limit", endpoints: 400, warning: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+
+			var endpoints []string
+
+			for i := 0; i < test.endpoints; i++ {
+				endpoints = append(endpoints, fmt.Sprintf("/endpoint-%d", i))
+			}
+
+			generator := Generator{
+				Config:    newTestConfig(t),
+				Duration:  newTestDuration(),
+				Errors:    newTestErrors(),
+				Requests:  newTestRequests(),
+				Endpoints: endpoints,
+				Logger:    slog.New(slog.NewTextHandler(&output, nil)),
+			}
+
+			runWithFakeClock(t, &generator, 1)
+
+			if got := strings.Contains(output.String(), "level=WARN"); got != test.warning {
+				t.Fatalf("invalid warning: wanted %v, got %v", test.warning, got)
+			}
+		})
+	}
+}
+
+func TestGeneratorDeterministicErrors(t *testing.T) {
+	tests := []struct {
+		errorsPercentage int
+		failed           []int
+	}{
+		{errorsPercentage: 0, failed: nil},
+		{errorsPercentage: 10, failed: []int{9, 19, 29}},
+		{errorsPercentage: 25, failed: []int{3, 7, 11, 15, 19, 23, 27}},
+		{errorsPercentage: 50, failed: []int{1, 3, 5, 7, 9, 11, 13, 15, 17, 19, 21, 23, 25, 27, 29}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.errorsPercentage), func(t *testing.T) {
+			config := newTestConfig(t)
+
+			if err := config.SetErrorsPercentage(test.errorsPercentage); err != nil {
+				t.Fatalf("set errors percentage: %v", err)
+			}
+
+			generator := Generator{
+				Config:              config,
+				DeterministicErrors: true,
+			}
+
+			var failed []int
+
+			for i := 0; i < 30; i++ {
+				if generator.shouldFailRequest(0, nil, config.Snapshot(), defaultRequestRate) {
+					failed = append(failed, i)
+				}
+			}
+
+			if diff := cmp.Diff(failed, test.failed); diff != "" {
+				t.Fatalf("invalid failed requests:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGeneratorRand(t *testing.T) {
+	run := func() []float64 {
+		config := newTestConfig(t)
+
+		if err := config.SetErrorsPercentage(50); err != nil {
+			t.Fatalf("set errors percentage: %v", err)
+		}
+
+		var duration recordingHistogramVec
+
+		generator := Generator{
+			Config:            config,
+			Duration:          &duration,
+			Errors:            newTestErrors(),
+			FastErrorDuration: 0.5,
+			Rand:              rand.New(rand.NewSource(42)),
+		}
+
+		for i := 0; i < 100; i++ {
+			generator.simulateRequest(0)
+		}
+
+		return duration.observations
+	}
+
+	if diff := cmp.Diff(run(), run()); diff != "" {
+		t.Fatalf("different durations:\n%s", diff)
+	}
+}
+
+func TestGeneratorDefaultSampler(t *testing.T) {
+	config := newTestConfig(t)
+
+	var duration recordingHistogramVec
+
+	// Deterministic errors with an errors percentage of zero don't use the
+	// source of randomness, which is only used to sample the durations.
+	generator := Generator{
+		Config:              config,
+		Duration:            &duration,
+		Errors:              newTestErrors(),
+		DeterministicErrors: true,
+		Rand:                rand.New(rand.NewSource(42)),
+	}
+
+	sampler := UniformSampler{
+		Config: config,
+		Rand:   rand.New(rand.NewSource(42)),
+	}
+
+	var wanted []float64
+
+	for i := 0; i < 100; i++ {
+		generator.simulateRequest(0)
+		wanted = append(wanted, sampler.Sample())
+	}
+
+	if diff := cmp.Diff(duration.observations, wanted); diff != "" {
+		t.Fatalf("invalid durations:\n%s", diff)
+	}
+}
+
+func TestGeneratorSummary(t *testing.T) {
+	duration := prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "duration",
+		Objectives: map[float64]float64{0.5: 0.05},
+	}, nil)
+
+	registry := prometheus.NewPedanticRegistry()
+
+	if err := registry.Register(duration); err != nil {
+		t.Fatalf("register summary: %v", err)
+	}
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: duration,
+		Errors:   newTestErrors(),
+	}
+
+	for i := 0; i < 10; i++ {
+		generator.simulateRequest(0)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+
+	if len(families) != 1 || len(families[0].Metric) != 1 {
+		t.Fatalf("invalid metrics: %v", families)
+	}
+
+	if got := families[0].Metric[0].GetSummary().GetSampleCount(); got != 10 {
+		t.Fatalf("invalid number of observations: %d", got)
+	}
+}
+
+// recordingGauge records its value after every increment and decrement.
+type recordingGauge struct {
+	mockGauge
+
+	history []float64
+}
+
+func (g *recordingGauge) Inc() {
+	g.mockGauge.Inc()
+	g.history = append(g.history, g.value)
+}
+
+func (g *recordingGauge) Dec() {
+	g.mockGauge.Dec()
+	g.history = append(g.history, g.value)
+}
+
+func TestGeneratorInFlight(t *testing.T) {
+	var inFlight recordingGauge
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: newTestDuration(),
+		Errors:   newTestErrors(),
+		InFlight: &inFlight,
+		Sampler: &SweepSampler{
+			Buckets: []float64{2.5},
+		},
+	}
+
+	runWithFakeClock(t, &generator, 100)
+
+	// Every request lasts 2.5s and starts 1s after the previous one, so at
+	// most three requests overlap.
+	for i, value := range inFlight.history {
+		if value < 0 || value > 3 {
+			t.Fatalf("invalid number of requests in flight at %d: %v", i, value)
+		}
+	}
+
+	// The last three requests didn't complete.
+	if inFlight.value != 3 {
+		t.Fatalf("invalid number of requests in flight: %v", inFlight.value)
+	}
+
+	// 101 increments and 98 decrements.
+	if got := len(inFlight.history); got != 199 {
+		t.Fatalf("invalid number of changes: %d", got)
+	}
+}
+
+func TestGeneratorFastErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		errorsPercentage int
+		wanted           float64
+	}{
+		{
+			name:             "failed",
+			errorsPercentage: 100,
+			wanted:           0.01,
+		},
+		{
+			name:             "succeeded",
+			errorsPercentage: 0,
+			wanted:           5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := newTestConfig(t)
+
+			if err := config.SetErrorsPercentage(test.errorsPercentage); err != nil {
+				t.Fatalf("set errors percentage: %v", err)
+			}
+
+			duration := newTestDuration()
+
+			generator := Generator{
+				Config:   config,
+				Duration: duration,
+				Errors:   newTestErrors(),
+				Sampler: &SweepSampler{
+					Buckets: []float64{5},
+				},
+				FastErrorDuration: 0.01,
+			}
+
+			for i := 0; i < 10; i++ {
+				generator.simulateRequest(0)
+			}
+
+			if got := histogramSum(t, duration, prometheus.Labels{}); math.Abs(got-10*test.wanted) > 1e-9 {
+				t.Fatalf("invalid sum of durations: wanted %v, got %v", 10*test.wanted, got)
+			}
+		})
+	}
+}
+
+func TestGeneratorPickStatusCode(t *testing.T) {
+	config := newTestConfig(t)
+
+	generator := Generator{
+		Config: config,
+	}
+
+	for _, code := range []int{404, 503} {
+		// Every distribution has a single status code with a weight, so that
+		// picking it again after replacing the distribution proves that the
+		// chooser was rebuilt.
+		err := config.SetStatusDistribution(limits.StatusDistribution{
+			{Code: 400, Weight: 0},
+			{Code: code, Weight: 1},
+		})
+		if err != nil {
+			t.Fatalf("set status distribution: %v", err)
+		}
+
+		for i := 0; i < 10; i++ {
+			settings := config.Snapshot()
+
+			if got := generator.pickStatusCode(settings.StatusDistribution, settings.StatusDistributionVersion); got != code {
+				t.Fatalf("invalid status code: wanted %d, got %d", code, got)
+			}
+		}
+	}
+}
+
+func TestGeneratorStatusDistribution(t *testing.T) {
+	const requests = 1000
+
+	config := newTestConfig(t)
+
+	if err := config.SetErrorsPercentage(100); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	err := config.SetStatusDistribution(limits.StatusDistribution{
+		{Code: 404, Weight: 3},
+		{Code: 503, Weight: 1},
+	})
+	if err != nil {
+		t.Fatalf("set status distribution: %v", err)
+	}
+
+	var (
+		errors  = newTestErrors()
+		counter = newTestRequests()
+	)
+
+	generator := Generator{
+		Config:   config,
+		Duration: newTestDuration(),
+		Errors:   errors,
+		Requests: counter,
+	}
+
+	for i := 0; i < requests; i++ {
+		generator.simulateRequest(0)
+	}
+
+	notFound := testutil.ToFloat64(counter.WithLabelValues(defaultEndpoint, defaultMethod, statusClassClientError, "404"))
+	unavailable := testutil.ToFloat64(counter.WithLabelValues(defaultEndpoint, defaultMethod, statusClassServerError, "503"))
+
+	if notFound+unavailable != requests {
+		t.Fatalf("invalid number of requests: %v", notFound+unavailable)
+	}
+
+	if notFound < 650 || notFound > 850 {
+		t.Fatalf("invalid number of 404 requests: %v", notFound)
+	}
+
+	if got := testutil.CollectAndCount(counter); got != 2 {
+		t.Fatalf("invalid number of series: %d", got)
+	}
+
+	if got := testutil.ToFloat64(errors.WithLabelValues(statusClassClientError)); got != notFound {
+		t.Fatalf("invalid number of client errors: wanted %v, got %v", notFound, got)
+	}
+}
+
+func TestGeneratorDurationScale(t *testing.T) {
+	for _, scale := range []float64{1, 2.5} {
+		config := newTestConfig(t)
+
+		if err := config.SetDurationInterval(4, 4); err != nil {
+			t.Fatalf("set duration interval: %v", err)
+		}
+
+		if err := config.SetDurationScale(scale); err != nil {
+			t.Fatalf("set duration scale: %v", err)
+		}
+
+		duration := newTestDuration()
+
+		generator := Generator{
+			Config:   config,
+			Duration: duration,
+			Errors:   newTestErrors(),
+		}
+
+		generator.simulateRequest(0)
+
+		if got := histogramSum(t, duration, prometheus.Labels{}); got != 4*scale {
+			t.Fatalf("invalid duration with scale %v: %v", scale, got)
+		}
+	}
+}
+
+// busyClock is a fake clock where every call to Now takes some time, like the
+// work done between two simulated requests. After end, busyClock cancels the
+// context and stops moving.
+type busyClock struct {
+	now    time.Time
+	work   time.Duration
+	end    time.Time
+	cancel context.CancelFunc
+}
+
+func (c *busyClock) Now() time.Time {
+	c.now = c.now.Add(c.work)
+	return c.now
+}
+
+func (c *busyClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+
+	if !c.now.Before(c.end) {
+		c.cancel()
+		return ch
+	}
+
+	ch <- c.now
+
+	return ch
+}
+
+func (c *busyClock) AfterFunc(time.Duration, func()) {}
+
+func TestGeneratorNoDrift(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Unix(1000, 0)
+
+	duration := newTestDuration()
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: duration,
+		Errors:   newTestErrors(),
+		Clock: &busyClock{
+			now:    start,
+			work:   100 * time.Millisecond,
+			end:    start.Add(10 * time.Second),
+			cancel: cancel,
+		},
+	}
+
+	if err := generator.Run(ctx); err != context.Canceled {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	// At the default rate of one request per second, the work between two
+	// requests must not delay the following ones.
+	if got := histogramCount(t, duration, prometheus.Labels{}); got != 10 {
+		t.Fatalf("invalid number of observations: %d", got)
+	}
+}
+
+// BenchmarkGeneratorIteration measures the work of every iteration of the
+// Generator: reading the rate, sampling the duration, deciding whether the
+// request fails and observing it. Sampling from the snapshot of the
+// configuration avoids reading the configuration a second time.
+func BenchmarkGeneratorIteration(b *testing.B) {
+	b.Run("snapshot", func(b *testing.B) {
+		benchmarkGeneratorIteration(b, func(config *limits.Config) Sampler {
+			return nil
+		})
+	})
+
+	b.Run("sampler", func(b *testing.B) {
+		benchmarkGeneratorIteration(b, func(config *limits.Config) Sampler {
+			return UniformSampler{Config: config}
+		})
+	})
+}
+
+func benchmarkGeneratorIteration(b *testing.B, sampler func(config *limits.Config) Sampler) {
+	var config limits.Config
+
+	if err := config.SetDurationInterval(1, 10); err != nil {
+		b.Fatalf("set duration interval: %v", err)
+	}
+
+	if err := config.SetErrorsPercentage(50); err != nil {
+		b.Fatalf("set errors percentage: %v", err)
+	}
+
+	generator := Generator{
+		Config:         &config,
+		Duration:       newTestDuration(),
+		Errors:         newTestErrors(),
+		Sampler:        sampler(&config),
+		CacheObservers: true,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		generator.simulateRequest(0)
+	}
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+)
+
+// RateSource returns the rate of the simulated requests, in requests per
+// second, after the given time has elapsed since the Generator started. The
+// returned rate must be greater than zero.
+type RateSource interface {
+	Rate(elapsed time.Duration) float64
+}
+
+// ConstantRate is a RateSource returning always the same rate.
+type ConstantRate float64
+
+func (r ConstantRate) Rate(time.Duration) float64 {
+	return float64(r)
+}
+
+// ConfigRate is a RateSource returning the request rate of Config. The request
+// rate is read every time, so changes to Config take effect immediately. If
+// Config doesn't specify a request rate, ConfigRate returns the default rate of
+// one request per second.
+type ConfigRate struct {
+	Config *limits.Config
+}
+
+func (r ConfigRate) Rate(time.Duration) float64 {
+	if rate := r.Config.RequestRate(); rate > 0 {
+		return float64(rate)
+	}
+
+	return defaultRequestRate
+}
+
+// Step is a step of a StepRate.
+type Step struct {
+	Rate     float64
+	Duration time.Duration
+}
+
+// StepRate is a RateSource that holds the rate of every step for the duration
+// of the step, then moves to the next step. After the last step, StepRate
+// either starts again from the first step or holds the rate of the last step
+// forever.
+type StepRate struct {
+	Steps []Step
+	Loop  bool
+}
+
+func (r StepRate) Rate(elapsed time.Duration) float64 {
+	if r.Loop {
+		elapsed %= r.totalDuration()
+	}
+
+	for _, step := range r.Steps {
+		if elapsed < step.Duration {
+			return step.Rate
+		}
+
+		elapsed -= step.Duration
+	}
+
+	return r.Steps[len(r.Steps)-1].Rate
+}
+
+func (r StepRate) totalDuration() time.Duration {
+	var total time.Duration
+
+	for _, step := range r.Steps {
+		total += step.Duration
+	}
+
+	return total
+}
+
+// SineRate is a RateSource oscillating sinusoidally between Min and Max over
+// Period. The rate starts halfway between Min and Max and grows first.
+type SineRate struct {
+	Min    float64
+	Max    float64
+	Period time.Duration
+}
+
+func (r SineRate) Rate(elapsed time.Duration) float64 {
+	phase := 2 * math.Pi * float64(elapsed%r.Period) / float64(r.Period)
+	return r.Min + (r.Max-r.Min)*(1+math.Sin(phase))/2
+}
+
+// SlowStart is a RateSource that ramps up the rate of Source during the initial
+// Window. The rate grows linearly from the fraction From of the rate of Source
+// to the full rate of Source. If Source is nil, SlowStart ramps up the default
+// rate of one request per second.
+type SlowStart struct {
+	Source RateSource
+	Window time.Duration
+	From   float64
+}
+
+func (r SlowStart) Rate(elapsed time.Duration) float64 {
+	rate := rateOf(r.Source, elapsed)
+
+	if elapsed >= r.Window {
+		return rate
+	}
+
+	progress := float64(elapsed) / float64(r.Window)
+
+	return rate * (r.From + (1-r.From)*progress)
+}
+
+// rateOf returns the rate of source, or the default rate if source is nil.
+func rateOf(source RateSource, elapsed time.Duration) float64 {
+	if source == nil {
+		return defaultRequestRate
+	}
+
+	return source.Rate(elapsed)
+}
+
+// ParseSteps parses a comma-separated list of steps in the form rate:duration,
+// where the duration is in the format accepted by time.ParseDuration.
+func ParseSteps(value string) ([]Step, error) {
+	var steps []Step
+
+	for _, part := range strings.Split(value, ",") {
+		step, err := parseStep(part)
+		if err != nil {
+			return nil, fmt.Errorf("step %q: %v", part, err)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+func parseStep(value string) (Step, error) {
+	fields := strings.Split(strings.TrimSpace(value), ":")
+
+	if len(fields) != 2 {
+		return Step{}, fmt.Errorf("not in the form rate:duration")
+	}
+
+	rate, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || rate <= 0 {
+		return Step{}, fmt.Errorf("rate is not a positive number")
+	}
+
+	duration, err := time.ParseDuration(fields[1])
+	if err != nil || duration <= 0 {
+		return Step{}, fmt.Errorf("duration is not a positive duration")
+	}
+
+	return Step{Rate: rate, Duration: duration}, nil
+}
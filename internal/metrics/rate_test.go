@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestStepRate(t *testing.T) {
+	rate := StepRate{
+		Steps: []Step{
+			{Rate: 5, Duration: time.Minute},
+			{Rate: 10, Duration: 30 * time.Second},
+		},
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		wanted  float64
+	}{
+		{elapsed: 0, wanted: 5},
+		{elapsed: 59 * time.Second, wanted: 5},
+		{elapsed: time.Minute, wanted: 10},
+		{elapsed: 89 * time.Second, wanted: 10},
+		{elapsed: 90 * time.Second, wanted: 10},
+		{elapsed: time.Hour, wanted: 10},
+	}
+
+	for _, test := range tests {
+		if got := rate.Rate(test.elapsed); got != test.wanted {
+			t.Fatalf("invalid rate at %v: wanted %v, got %v", test.elapsed, test.wanted, got)
+		}
+	}
+}
+
+func TestStepRateLoop(t *testing.T) {
+	rate := StepRate{
+		Steps: []Step{
+			{Rate: 5, Duration: time.Minute},
+			{Rate: 10, Duration: 30 * time.Second},
+		},
+		Loop: true,
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		wanted  float64
+	}{
+		{elapsed: 0, wanted: 5},
+		{elapsed: time.Minute, wanted: 10},
+		{elapsed: 90 * time.Second, wanted: 5},
+		{elapsed: 150 * time.Second, wanted: 10},
+		{elapsed: 180 * time.Second, wanted: 5},
+	}
+
+	for _, test := range tests {
+		if got := rate.Rate(test.elapsed); got != test.wanted {
+			t.Fatalf("invalid rate at %v: wanted %v, got %v", test.elapsed, test.wanted, got)
+		}
+	}
+}
+
+func TestSlowStart(t *testing.T) {
+	rate := SlowStart{
+		Source: ConstantRate(10),
+		Window: 10 * time.Second,
+		From:   0.1,
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		wanted  float64
+	}{
+		{elapsed: 0, wanted: 1},
+		{elapsed: 5 * time.Second, wanted: 5.5},
+		{elapsed: 9 * time.Second, wanted: 9.1},
+		{elapsed: 10 * time.Second, wanted: 10},
+		{elapsed: time.Hour, wanted: 10},
+	}
+
+	for _, test := range tests {
+		if got := rate.Rate(test.elapsed); math.Abs(got-test.wanted) > 1e-9 {
+			t.Fatalf("invalid rate at %v: wanted %v, got %v", test.elapsed, test.wanted, got)
+		}
+	}
+}
+
+func TestSlowStartDefaultRate(t *testing.T) {
+	rate := SlowStart{
+		Window: 10 * time.Second,
+		From:   0.5,
+	}
+
+	if got := rate.Rate(0); got != 0.5 {
+		t.Fatalf("invalid initial rate: %v", got)
+	}
+
+	if got := rate.Rate(10 * time.Second); got != defaultRequestRate {
+		t.Fatalf("invalid final rate: %v", got)
+	}
+}
+
+func TestConfigRate(t *testing.T) {
+	config := newTestConfig(t)
+
+	rate := ConfigRate{Config: config}
+
+	if got := rate.Rate(0); got != defaultRequestRate {
+		t.Fatalf("invalid default rate: %v", got)
+	}
+
+	if err := config.SetRequestRate(5); err != nil {
+		t.Fatalf("set request rate: %v", err)
+	}
+
+	if got := rate.Rate(0); got != 5 {
+		t.Fatalf("invalid rate: %v", got)
+	}
+}
+
+func TestGeneratorConfigRate(t *testing.T) {
+	config := newTestConfig(t)
+
+	if err := config.SetRequestRate(5); err != nil {
+		t.Fatalf("set request rate: %v", err)
+	}
+
+	var heartbeat mockGauge
+
+	duration := newTestDuration()
+
+	generator := Generator{
+		Config:    config,
+		Duration:  duration,
+		Errors:    newTestErrors(),
+		Heartbeat: &heartbeat,
+	}
+
+	runWithFakeClock(t, &generator, 10)
+
+	var firstSecond int
+
+	for _, value := range heartbeat.values {
+		if value < 1001 {
+			firstSecond++
+		}
+	}
+
+	if firstSecond != 5 {
+		t.Fatalf("invalid number of requests in the first second: %d", firstSecond)
+	}
+
+	if got := histogramCount(t, duration, prometheus.Labels{}); got != 11 {
+		t.Fatalf("invalid number of observations: %d", got)
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	steps, err := ParseSteps("5:1m, 0.5:30s")
+	if err != nil {
+		t.Fatalf("parse steps: %v", err)
+	}
+
+	wanted := []Step{
+		{Rate: 5, Duration: time.Minute},
+		{Rate: 0.5, Duration: 30 * time.Second},
+	}
+
+	if diff := cmp.Diff(steps, wanted); diff != "" {
+		t.Fatalf("invalid steps:\n%s", diff)
+	}
+}
+
+func TestParseStepsError(t *testing.T) {
+	for _, value := range []string{"", "5", "boom:1m", "0:1m", "5:boom", "5:0s", "5:1m:2"} {
+		if _, err := ParseSteps(value); err == nil {
+			t.Fatalf("no error returned for %q", value)
+		}
+	}
+}
+
+func TestGeneratorStepRate(t *testing.T) {
+	var heartbeat mockGauge
+
+	generator := Generator{
+		Config:    newTestConfig(t),
+		Duration:  newTestDuration(),
+		Errors:    newTestErrors(),
+		Heartbeat: &heartbeat,
+		Rate: StepRate{
+			Steps: []Step{
+				{Rate: 1, Duration: 2 * time.Second},
+				{Rate: 2, Duration: time.Second},
+			},
+		},
+	}
+
+	runWithFakeClock(t, &generator, 5)
+
+	wanted := []float64{1000, 1001, 1002, 1002.5, 1003, 1003.5}
+
+	if diff := cmp.Diff(heartbeat.values, wanted); diff != "" {
+		t.Fatalf("invalid heartbeat values:\n%s", diff)
+	}
+}
+
+func TestSineRate(t *testing.T) {
+	rate := SineRate{
+		Min:    2,
+		Max:    10,
+		Period: time.Minute,
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		wanted  float64
+	}{
+		{elapsed: 0, wanted: 6},
+		{elapsed: 15 * time.Second, wanted: 10},
+		{elapsed: 30 * time.Second, wanted: 6},
+		{elapsed: 45 * time.Second, wanted: 2},
+		{elapsed: time.Minute, wanted: 6},
+		{elapsed: 75 * time.Second, wanted: 10},
+	}
+
+	for _, test := range tests {
+		if got := rate.Rate(test.elapsed); math.Abs(got-test.wanted) > 1e-9 {
+			t.Fatalf("invalid rate at %v: wanted %v, got %v", test.elapsed, test.wanted, got)
+		}
+	}
+
+	// Sample the rate across a period and check that it stays within bounds.
+	for elapsed := time.Duration(0); elapsed < rate.Period; elapsed += 100 * time.Millisecond {
+		if got := rate.Rate(elapsed); got < rate.Min || got > rate.Max {
+			t.Fatalf("rate at %v out of bounds: %v", elapsed, got)
+		}
+	}
+}
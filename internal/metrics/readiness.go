@@ -0,0 +1,25 @@
+package metrics
+
+import "sync/atomic"
+
+// Readiness reports whether a Generator is simulating requests. It is safe for
+// concurrent use, so that it can be checked while the Generator runs.
+type Readiness struct {
+	running uint32
+}
+
+// Ready returns true after the Generator simulated its first request, until
+// the Generator stops.
+func (r *Readiness) Ready() bool {
+	return atomic.LoadUint32(&r.running) == 1
+}
+
+func (r *Readiness) set(running bool) {
+	var value uint32
+
+	if running {
+		value = 1
+	}
+
+	atomic.StoreUint32(&r.running, value)
+}
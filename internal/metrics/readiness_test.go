@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGeneratorReadiness(t *testing.T) {
+	var readiness Readiness
+
+	generator := Generator{
+		Config:    newTestConfig(t),
+		Duration:  newTestDuration(),
+		Errors:    newTestErrors(),
+		Readiness: &readiness,
+	}
+
+	if readiness.Ready() {
+		t.Fatalf("ready before running")
+	}
+
+	var readyWhileRunning bool
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	generator.Clock = &readinessClock{
+		now: time.Unix(1000, 0),
+		wait: func() {
+			readyWhileRunning = readiness.Ready()
+			cancel()
+		},
+	}
+
+	if err := generator.Run(ctx); err != context.Canceled {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	if !readyWhileRunning {
+		t.Fatalf("not ready while running")
+	}
+
+	if readiness.Ready() {
+		t.Fatalf("ready after stopping")
+	}
+}
+
+// readinessClock calls wait the first time the Generator waits.
+type readinessClock struct {
+	now  time.Time
+	wait func()
+}
+
+func (c *readinessClock) Now() time.Time {
+	return c.now
+}
+
+func (c *readinessClock) After(d time.Duration) <-chan time.Time {
+	c.wait()
+	return make(chan time.Time)
+}
+
+func (c *readinessClock) AfterFunc(d time.Duration, f func()) {}
@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+)
+
+// UniformSampler picks durations at random from the duration interval in
+// Config, with every duration in the interval being equally likely. Durations
+// are not limited to whole seconds.
+type UniformSampler struct {
+	Config *limits.Config
+
+	// Rand, if not nil, is the source of randomness. Otherwise, the default
+	// source of the math/rand package is used.
+	Rand *rand.Rand
+}
+
+func (s UniformSampler) Sample() float64 {
+	min, max := s.Config.DurationIntervalSeconds()
+	return randomDurationBetween(s.Rand, min, max)
+}
+
+func randomDurationBetween(r *rand.Rand, min, max float64) float64 {
+	if r == nil {
+		return min + rand.Float64()*(max-min)
+	}
+
+	return min + r.Float64()*(max-min)
+}
+
+// NormalSampler picks durations from a normal distribution. Durations are
+// clamped to the duration interval in Config.
+type NormalSampler struct {
+	Config *limits.Config
+
+	// Mean is the mean of the distribution, in seconds. If Mean is not greater
+	// than zero, the middle of the duration interval is used.
+	Mean float64
+
+	// StdDev is the standard deviation of the distribution, in seconds.
+	StdDev float64
+
+	// Rand, if not nil, is the source of randomness. Otherwise, the default
+	// source of the math/rand package is used.
+	Rand *rand.Rand
+}
+
+func (s NormalSampler) Sample() float64 {
+	min, max := s.Config.DurationIntervalSeconds()
+
+	mean := s.Mean
+
+	if mean <= 0 {
+		mean = (min + max) / 2
+	}
+
+	return clamp(mean+normFloat64(s.Rand)*s.StdDev, min, max)
+}
+
+// ExponentialSampler picks durations from an exponential distribution, which
+// simulates the long tail of the latency of queueing systems. The rate
+// parameter of the distribution is the duration lambda in Config, so the mean
+// of the distribution is 1/lambda seconds. Durations are clamped to the
+// duration interval in Config.
+type ExponentialSampler struct {
+	Config *limits.Config
+
+	// Rand, if not nil, is the source of randomness. Otherwise, the default
+	// source of the math/rand package is used.
+	Rand *rand.Rand
+}
+
+func (s ExponentialSampler) Sample() float64 {
+	settings := s.Config.Snapshot()
+	return clamp(expFloat64(s.Rand)/settings.DurationLambda, settings.MinDuration, settings.MaxDuration)
+}
+
+func expFloat64(r *rand.Rand) float64 {
+	if r == nil {
+		return rand.ExpFloat64()
+	}
+
+	return r.ExpFloat64()
+}
+
+func normFloat64(r *rand.Rand) float64 {
+	if r == nil {
+		return rand.NormFloat64()
+	}
+
+	return r.NormFloat64()
+}
+
+func clamp(value, min, max float64) float64 {
+	return math.Min(math.Max(value, min), max)
+}
+
+// JitterSampler adds Gaussian noise to the durations returned by Sampler, to
+// make them look more organic. Durations are never less than zero.
+type JitterSampler struct {
+	Sampler Sampler
+
+	// StdDev is the standard deviation of the noise, in seconds.
+	StdDev float64
+
+	// Rand, if not nil, is the source of randomness. Otherwise, the default
+	// source of the math/rand package is used.
+	Rand *rand.Rand
+}
+
+func (s JitterSampler) Sample() float64 {
+	return math.Max(0, s.Sampler.Sample()+normFloat64(s.Rand)*s.StdDev)
+}
+
+// OutlierSampler clamps the durations returned by Sampler to a quantile of the
+// recent durations plus a margin, preventing outliers from a noisy
+// distribution. Durations are not clamped until Window durations have been
+// sampled.
+type OutlierSampler struct {
+	Sampler Sampler
+
+	// Window is the number of recent durations the quantile is computed on.
+	Window int
+
+	// Quantile is the quantile of the recent durations, between 0 and 1, that
+	// bounds the durations.
+	Quantile float64
+
+	// Margin is added to the quantile of the recent durations, in seconds.
+	Margin float64
+
+	// recent holds the recent durations in the order they were sampled, while
+	// sorted holds the same durations in increasing order. Keeping sorted up to
+	// date on every sample avoids sorting the whole window on every request.
+	recent []float64
+	sorted []float64
+	next   int
+}
+
+func (s *OutlierSampler) Sample() float64 {
+	sample := s.Sampler.Sample()
+
+	// The bound is computed before recording the sample, so that an outlier
+	// doesn't raise its own bound.
+	result := sample
+
+	if len(s.recent) == s.Window {
+		result = math.Min(sample, s.bound())
+	}
+
+	// The window records the original durations, so the bound follows the
+	// changes of the underlying distribution.
+	if len(s.recent) < s.Window {
+		s.recent = append(s.recent, sample)
+	} else {
+		s.removeSorted(s.recent[s.next])
+		s.recent[s.next] = sample
+		s.next = (s.next + 1) % s.Window
+	}
+
+	s.insertSorted(sample)
+
+	return result
+}
+
+// bound returns the quantile of the recent durations, using the nearest-rank
+// method, plus the margin.
+func (s *OutlierSampler) bound() float64 {
+	rank := int(math.Ceil(s.Quantile*float64(len(s.sorted)))) - 1
+
+	if rank < 0 {
+		rank = 0
+	}
+
+	return s.sorted[rank] + s.Margin
+}
+
+func (s *OutlierSampler) insertSorted(value float64) {
+	i := sort.SearchFloat64s(s.sorted, value)
+	s.sorted = append(s.sorted, 0)
+	copy(s.sorted[i+1:], s.sorted[i:])
+	s.sorted[i] = value
+}
+
+func (s *OutlierSampler) removeSorted(value float64) {
+	i := sort.SearchFloat64s(s.sorted, value)
+	s.sorted = append(s.sorted[:i], s.sorted[i+1:]...)
+}
+
+// WarmupSampler simulates a service that is slow right after it starts. The
+// first durations are close to the maximum duration of Config, and they
+// gradually approach the durations returned by Sampler over Warmup. After
+// Warmup, the durations are the ones returned by Sampler. Warmup starts with
+// the first sample.
+type WarmupSampler struct {
+	Sampler Sampler
+	Config  *limits.Config
+	Warmup  time.Duration
+
+	// Clock tells the time. If Clock is nil, the system clock is used.
+	Clock Clock
+
+	start time.Time
+}
+
+func (s *WarmupSampler) Sample() float64 {
+	now := s.clock().Now()
+
+	if s.start.IsZero() {
+		s.start = now
+	}
+
+	sample := s.Sampler.Sample()
+
+	progress := float64(now.Sub(s.start)) / float64(s.Warmup)
+
+	if progress >= 1 {
+		return sample
+	}
+
+	_, max := s.Config.DurationIntervalSeconds()
+
+	return (1-progress)*max + progress*sample
+}
+
+func (s *WarmupSampler) clock() Clock {
+	if s.Clock == nil {
+		return realClock{}
+	}
+
+	return s.Clock
+}
+
+// SweepSampler deterministically walks through the upper bounds of the buckets
+// of a histogram, one bucket per sample. After the last bucket, SweepSampler
+// starts again from the first one. This fills every bucket in order, which is
+// useful to show how histogram buckets work.
+type SweepSampler struct {
+	Buckets []float64
+
+	next int
+}
+
+func (s *SweepSampler) Sample() float64 {
+	sample := s.Buckets[s.next]
+	s.next = (s.next + 1) % len(s.Buckets)
+	return sample
+}
+
+// QuantileSampler deterministically cycles through fixed quantiles of the
+// duration interval, one quantile per sample. A quantile of 0 corresponds to
+// the minimum duration and a quantile of 1 to the maximum duration. This
+// produces a perfectly shaped distribution, which is useful for reproducible
+// percentile demos.
+type QuantileSampler struct {
+	Config    *limits.Config
+	Quantiles []float64
+
+	next int
+}
+
+func (s *QuantileSampler) Sample() float64 {
+	min, max := s.Config.DurationIntervalSeconds()
+
+	quantile := s.Quantiles[s.next]
+	s.next = (s.next + 1) % len(s.Quantiles)
+
+	return min + quantile*(max-min)
+}
+
+// ParseObjectives parses a comma-separated list of quantiles between 0 and 1,
+// and returns them as the objectives of a summary. The allowed error of every
+// quantile is a tenth of the distance of the quantile from 1.
+func ParseObjectives(value string) (map[float64]float64, error) {
+	quantiles, err := ParseQuantiles(value)
+	if err != nil {
+		return nil, err
+	}
+
+	objectives := make(map[float64]float64)
+
+	for _, quantile := range quantiles {
+		objectives[quantile] = (1 - quantile) / 10
+	}
+
+	return objectives, nil
+}
+
+// ParseQuantiles parses a comma-separated list of quantiles between 0 and 1.
+func ParseQuantiles(value string) ([]float64, error) {
+	var quantiles []float64
+
+	for _, part := range strings.Split(value, ",") {
+		quantile, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("quantile %q is not a number", part)
+		}
+
+		if quantile < 0 || quantile > 1 {
+			return nil, fmt.Errorf("quantile %q is not between 0 and 1", part)
+		}
+
+		quantiles = append(quantiles, quantile)
+	}
+
+	return quantiles, nil
+}
@@ -0,0 +1,363 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestUniformSampler(t *testing.T) {
+	var config limits.Config
+
+	if err := config.SetDurationIntervalSeconds(0.05, 0.5); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	sampler := UniformSampler{
+		Config: &config,
+		Rand:   rand.New(rand.NewSource(1)),
+	}
+
+	for i := 0; i < 1000; i++ {
+		if sample := sampler.Sample(); sample < 0.05 || sample > 0.5 {
+			t.Fatalf("sample out of the duration interval: %v", sample)
+		}
+	}
+}
+
+func TestNormalSampler(t *testing.T) {
+	var config limits.Config
+
+	if err := config.SetDurationInterval(10, 20); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	sampler := NormalSampler{
+		Config: &config,
+		StdDev: 5,
+		Rand:   rand.New(rand.NewSource(1)),
+	}
+
+	var clampedMin, clampedMax bool
+
+	for i := 0; i < 10000; i++ {
+		sample := sampler.Sample()
+
+		if sample < 10 || sample > 20 {
+			t.Fatalf("sample out of the duration interval: %v", sample)
+		}
+
+		clampedMin = clampedMin || sample == 10
+		clampedMax = clampedMax || sample == 20
+	}
+
+	if !clampedMin || !clampedMax {
+		t.Fatalf("samples not clamped to the duration interval")
+	}
+}
+
+func TestNormalSamplerMean(t *testing.T) {
+	var config limits.Config
+
+	if err := config.SetDurationInterval(1, 100); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	sampler := NormalSampler{
+		Config: &config,
+		Mean:   30,
+		StdDev: 1,
+		Rand:   rand.New(rand.NewSource(1)),
+	}
+
+	var sum float64
+
+	for i := 0; i < 10000; i++ {
+		sum += sampler.Sample()
+	}
+
+	if mean := sum / 10000; math.Abs(mean-30) > 0.1 {
+		t.Fatalf("invalid mean: %v", mean)
+	}
+}
+
+func TestExponentialSampler(t *testing.T) {
+	var config limits.Config
+
+	if err := config.SetDurationInterval(1, 100); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	if err := config.SetDurationLambda(0.1); err != nil {
+		t.Fatalf("set duration lambda: %v", err)
+	}
+
+	sampler := ExponentialSampler{
+		Config: &config,
+		Rand:   rand.New(rand.NewSource(1)),
+	}
+
+	var sum float64
+
+	for i := 0; i < 100000; i++ {
+		sample := sampler.Sample()
+
+		if sample < 1 || sample > 100 {
+			t.Fatalf("sample out of the duration interval: %v", sample)
+		}
+
+		sum += sample
+	}
+
+	// Clamping to the duration interval slightly moves the mean away from
+	// 1/lambda.
+	if mean := sum / 100000; math.Abs(mean-10) > 0.5 {
+		t.Fatalf("invalid mean: %v", mean)
+	}
+}
+
+func TestWarmupSampler(t *testing.T) {
+	var config limits.Config
+
+	if err := config.SetDurationIntervalSeconds(1, 10); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	clock := fakeClock{
+		now: time.Unix(1000, 0),
+	}
+
+	sampler := WarmupSampler{
+		Sampler: &SweepSampler{
+			Buckets: []float64{2},
+		},
+		Config: &config,
+		Warmup: 4 * time.Second,
+		Clock:  &clock,
+	}
+
+	var samples []float64
+
+	for i := 0; i < 6; i++ {
+		samples = append(samples, sampler.Sample())
+		clock.now = clock.now.Add(time.Second)
+	}
+
+	wanted := []float64{10, 8, 6, 4, 2, 2}
+
+	if diff := cmp.Diff(samples, wanted); diff != "" {
+		t.Fatalf("invalid samples:\n%s", diff)
+	}
+}
+
+func TestSweepSampler(t *testing.T) {
+	sampler := SweepSampler{
+		Buckets: []float64{0.1, 0.5, 1, 5},
+	}
+
+	var samples []float64
+
+	for i := 0; i < 10; i++ {
+		samples = append(samples, sampler.Sample())
+	}
+
+	wanted := []float64{0.1, 0.5, 1, 5, 0.1, 0.5, 1, 5, 0.1, 0.5}
+
+	if diff := cmp.Diff(samples, wanted); diff != "" {
+		t.Fatalf("invalid samples:\n%s", diff)
+	}
+}
+
+func TestQuantileSampler(t *testing.T) {
+	var config limits.Config
+
+	if err := config.SetDurationInterval(10, 50); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	sampler := QuantileSampler{
+		Config:    &config,
+		Quantiles: []float64{0, 0.25, 0.5, 0.75, 1},
+	}
+
+	var samples []float64
+
+	for i := 0; i < 7; i++ {
+		samples = append(samples, sampler.Sample())
+	}
+
+	wanted := []float64{10, 20, 30, 40, 50, 10, 20}
+
+	if diff := cmp.Diff(samples, wanted); diff != "" {
+		t.Fatalf("invalid samples:\n%s", diff)
+	}
+}
+
+func TestParseQuantiles(t *testing.T) {
+	quantiles, err := ParseQuantiles("0, 0.5,1")
+	if err != nil {
+		t.Fatalf("parse quantiles: %v", err)
+	}
+
+	if diff := cmp.Diff(quantiles, []float64{0, 0.5, 1}); diff != "" {
+		t.Fatalf("invalid quantiles:\n%s", diff)
+	}
+}
+
+func TestParseQuantilesError(t *testing.T) {
+	for _, value := range []string{"", "boom", "0,1.5", "-0.1"} {
+		if _, err := ParseQuantiles(value); err == nil {
+			t.Fatalf("no error returned for %q", value)
+		}
+	}
+}
+
+func TestJitterSampler(t *testing.T) {
+	const samples = 10000
+
+	sampler := JitterSampler{
+		Sampler: &SweepSampler{
+			Buckets: []float64{5},
+		},
+		StdDev: 1,
+	}
+
+	var sum, sumOfSquares float64
+
+	for i := 0; i < samples; i++ {
+		sample := sampler.Sample()
+		sum += sample
+		sumOfSquares += sample * sample
+	}
+
+	mean := sum / samples
+	variance := sumOfSquares/samples - mean*mean
+
+	if math.Abs(mean-5) > 0.1 {
+		t.Fatalf("invalid mean: %v", mean)
+	}
+
+	if math.Abs(variance-1) > 0.1 {
+		t.Fatalf("invalid variance: %v", variance)
+	}
+}
+
+func TestOutlierSampler(t *testing.T) {
+	sampler := OutlierSampler{
+		Sampler: &SweepSampler{
+			Buckets: []float64{1, 2, 3, 4, 100, 4, 100, 2},
+		},
+		Window:   4,
+		Quantile: 0.75,
+		Margin:   0.5,
+	}
+
+	var samples []float64
+
+	for i := 0; i < 8; i++ {
+		samples = append(samples, sampler.Sample())
+	}
+
+	// The window is full after the first four samples. The first outlier is
+	// clamped to the third of {1, 2, 3, 4} plus the margin, the second one to
+	// the third of {3, 4, 4, 100} plus the margin.
+	wanted := []float64{1, 2, 3, 4, 3.5, 4, 4.5, 2}
+
+	if diff := cmp.Diff(samples, wanted); diff != "" {
+		t.Fatalf("invalid samples:\n%s", diff)
+	}
+}
+
+func TestOutlierSamplerBound(t *testing.T) {
+	var config limits.Config
+
+	if err := config.SetDurationInterval(1, 10); err != nil {
+		t.Fatalf("set duration interval: %v", err)
+	}
+
+	sampler := OutlierSampler{
+		Sampler: UniformSampler{
+			Config: &config,
+			Rand:   rand.New(rand.NewSource(1)),
+		},
+		Window:   50,
+		Quantile: 0.9,
+	}
+
+	for i := 0; i < 1000; i++ {
+		sampler.Sample()
+
+		if len(sampler.recent) < sampler.Window {
+			continue
+		}
+
+		sorted := append([]float64(nil), sampler.recent...)
+		sort.Float64s(sorted)
+
+		if diff := cmp.Diff(sampler.sorted, sorted); diff != "" {
+			t.Fatalf("invalid sorted durations at sample %d:\n%s", i, diff)
+		}
+	}
+}
+
+func BenchmarkOutlierSampler(b *testing.B) {
+	var config limits.Config
+
+	if err := config.SetDurationInterval(1, 10); err != nil {
+		b.Fatalf("set duration interval: %v", err)
+	}
+
+	sampler := OutlierSampler{
+		Sampler: UniformSampler{
+			Config: &config,
+		},
+		Window:   1000,
+		Quantile: 0.99,
+	}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		sampler.Sample()
+	}
+}
+
+func TestJitterSamplerNonNegative(t *testing.T) {
+	sampler := JitterSampler{
+		Sampler: &SweepSampler{
+			Buckets: []float64{0.1},
+		},
+		StdDev: 1,
+	}
+
+	for i := 0; i < 10000; i++ {
+		if sample := sampler.Sample(); sample < 0 {
+			t.Fatalf("negative sample: %v", sample)
+		}
+	}
+}
+
+func TestParseObjectives(t *testing.T) {
+	objectives, err := ParseObjectives("0.5,0.9,0.99")
+	if err != nil {
+		t.Fatalf("parse objectives: %v", err)
+	}
+
+	wanted := map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+	if diff := cmp.Diff(objectives, wanted, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Fatalf("invalid objectives:\n%s", diff)
+	}
+}
+
+func TestParseObjectivesError(t *testing.T) {
+	if _, err := ParseObjectives("0.5,boom"); err == nil {
+		t.Fatalf("no error returned")
+	}
+}
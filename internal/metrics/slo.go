@@ -0,0 +1,39 @@
+package metrics
+
+// SLO computes the compliance of the most recent simulated requests with a
+// latency objective. A request is compliant if it didn't fail and if its
+// duration doesn't exceed the objective.
+type SLO struct {
+	// Objective is the maximum duration of a compliant request, in seconds.
+	Objective float64
+
+	// Window is the number of recent requests the compliance is computed on.
+	Window int
+
+	outcomes  []bool
+	next      int
+	compliant int
+}
+
+// record records the outcome of a request and returns the fraction of
+// compliant requests in the window.
+func (s *SLO) record(duration float64, failed bool) float64 {
+	compliant := !failed && duration <= s.Objective
+
+	if len(s.outcomes) < s.Window {
+		s.outcomes = append(s.outcomes, compliant)
+	} else {
+		if s.outcomes[s.next] {
+			s.compliant--
+		}
+
+		s.outcomes[s.next] = compliant
+		s.next = (s.next + 1) % s.Window
+	}
+
+	if compliant {
+		s.compliant++
+	}
+
+	return float64(s.compliant) / float64(len(s.outcomes))
+}
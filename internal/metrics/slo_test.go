@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSLO(t *testing.T) {
+	slo := SLO{
+		Objective: 1,
+		Window:    4,
+	}
+
+	outcomes := []struct {
+		duration float64
+		failed   bool
+	}{
+		{duration: 0.5},
+		{duration: 1},
+		{duration: 2},
+		{duration: 0.5, failed: true},
+		{duration: 2},
+		{duration: 0.5},
+		{duration: 0.5},
+		{duration: 0.5},
+	}
+
+	var ratios []float64
+
+	for _, outcome := range outcomes {
+		ratios = append(ratios, slo.record(outcome.duration, outcome.failed))
+	}
+
+	wanted := []float64{1, 1, 2.0 / 3, 0.5, 0.25, 0.25, 0.5, 0.75}
+
+	if diff := cmp.Diff(ratios, wanted); diff != "" {
+		t.Fatalf("invalid compliance ratios:\n%s", diff)
+	}
+
+	if len(slo.outcomes) != slo.Window {
+		t.Fatalf("invalid number of outcomes: %d", len(slo.outcomes))
+	}
+}
+
+func TestGeneratorSLO(t *testing.T) {
+	var compliance mockGauge
+
+	config := newTestConfig(t)
+
+	if err := config.SetErrorsPercentage(0); err != nil {
+		t.Fatalf("set errors percentage: %v", err)
+	}
+
+	generator := Generator{
+		Config:   config,
+		Duration: newTestDuration(),
+		Errors:   newTestErrors(),
+		Sampler: &SweepSampler{
+			Buckets: []float64{1, 2, 3, 4},
+		},
+		SLO: &SLO{
+			Objective: 2,
+			Window:    4,
+		},
+		SLOCompliance: &compliance,
+	}
+
+	runWithFakeClock(t, &generator, 5)
+
+	wanted := []float64{1, 1, 2.0 / 3, 0.5, 0.5, 0.5}
+
+	if diff := cmp.Diff(compliance.values, wanted); diff != "" {
+		t.Fatalf("invalid compliance ratios:\n%s", diff)
+	}
+}
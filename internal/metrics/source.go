@@ -0,0 +1,32 @@
+package metrics
+
+// SplitMixSource is a source of random numbers implementing the SplitMix64
+// algorithm. Unlike the default source of the math/rand package, the sequence
+// of numbers generated by SplitMixSource is fully defined by this package, so a
+// given seed produces the same sequence regardless of the Go version.
+type SplitMixSource struct {
+	state uint64
+}
+
+// NewSplitMixSource returns a SplitMixSource initialized with the given seed.
+func NewSplitMixSource(seed int64) *SplitMixSource {
+	return &SplitMixSource{state: uint64(seed)}
+}
+
+func (s *SplitMixSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *SplitMixSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
+
+func (s *SplitMixSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitMixSource(t *testing.T) {
+	source := NewSplitMixSource(0)
+
+	var values []uint64
+
+	for i := 0; i < 3; i++ {
+		values = append(values, source.Uint64())
+	}
+
+	// Reference values of SplitMix64 for a seed of zero.
+	wanted := []uint64{0xe220a8397b1dcdaf, 0x6e789e6aa1b965f4, 0x06c45d188009454f}
+
+	if diff := cmp.Diff(values, wanted); diff != "" {
+		t.Fatalf("invalid values:\n%s", diff)
+	}
+}
+
+func TestSplitMixSourceSeed(t *testing.T) {
+	source := NewSplitMixSource(42)
+
+	first := source.Int63()
+
+	source.Seed(42)
+
+	if got := source.Int63(); got != first {
+		t.Fatalf("invalid value after seeding: wanted %d, got %d", first, got)
+	}
+}
+
+func TestSplitMixSourceIndependentFromGlobalRand(t *testing.T) {
+	sample := func() []int {
+		r := rand.New(NewSplitMixSource(42))
+
+		var values []int
+
+		for i := 0; i < 10; i++ {
+			values = append(values, r.Intn(100))
+		}
+
+		return values
+	}
+
+	rand.Seed(1)
+	first := sample()
+
+	rand.Seed(2)
+	second := sample()
+
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Fatalf("different values:\n%s", diff)
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"time"
+)
+
+// Staleness periodically stops updating one series for a while, simulating a
+// target that stops reporting and comes back. The series is still exported
+// during the pause with its last values, so its counters flatline and their
+// rate drops to zero, but the series doesn't disappear.
+type Staleness struct {
+	// Period is how often a gap happens. If Period is zero, gaps are
+	// disabled.
+	Period time.Duration
+
+	// Gap is how long a gap lasts. Every gap happens at the end of its period,
+	// so the Generator starts by updating every series.
+	Gap time.Duration
+}
+
+func (s Staleness) enabled() bool {
+	return s.Period > 0
+}
+
+// gap returns the index of the gap in progress after the given time has
+// elapsed since the Generator started, or false if no gap is in progress.
+func (s Staleness) gap(elapsed time.Duration) (int64, bool) {
+	if !s.enabled() || elapsed%s.Period < s.Period-s.Gap {
+		return 0, false
+	}
+
+	return int64(elapsed / s.Period), true
+}
+
+// staleSeries tracks the series that is not updated during the gap in
+// progress. The series is the one of the first request simulated during the
+// gap.
+type staleSeries struct {
+	// gap is the index of the gap in progress plus one, so that the zero value
+	// doesn't match any gap.
+	gap     int64
+	series  []byte
+	scratch []byte
+}
+
+// stale returns true if the series with the given label values must not be
+// updated during the given gap.
+func (s *staleSeries) stale(gap int64, labelValues []string) bool {
+	if s.gap != gap+1 {
+		s.gap = gap + 1
+		s.series = appendKey(s.series[:0], labelValues)
+		return true
+	}
+
+	s.scratch = appendKey(s.scratch[:0], labelValues)
+	return bytes.Equal(s.scratch, s.series)
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestStalenessGap(t *testing.T) {
+	staleness := Staleness{
+		Period: 4 * time.Second,
+		Gap:    time.Second,
+	}
+
+	var gaps []int64
+
+	for elapsed := time.Duration(0); elapsed < 10*time.Second; elapsed += time.Second {
+		gap, ok := staleness.gap(elapsed)
+		if !ok {
+			gap = -1
+		}
+
+		gaps = append(gaps, gap)
+	}
+
+	wanted := []int64{-1, -1, -1, 0, -1, -1, -1, 1, -1, -1}
+
+	if diff := cmp.Diff(gaps, wanted); diff != "" {
+		t.Fatalf("invalid gaps:\n%s", diff)
+	}
+}
+
+func TestStalenessDisabled(t *testing.T) {
+	if _, ok := (Staleness{}).gap(time.Hour); ok {
+		t.Fatalf("gap in progress")
+	}
+}
+
+func TestGeneratorStaleness(t *testing.T) {
+	duration := newTestDuration()
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: duration,
+		Errors:   newTestErrors(),
+		Staleness: Staleness{
+			Period: 4 * time.Second,
+			Gap:    2 * time.Second,
+		},
+	}
+
+	// The series is observed at 0s and 1s, skipped during the gap at 2s and
+	// 3s, and observed again at 4s and 5s.
+	runWithFakeClock(t, &generator, 5)
+
+	if got := histogramCount(t, duration, prometheus.Labels{}); got != 4 {
+		t.Fatalf("invalid number of observations: %d", got)
+	}
+}
+
+func TestGeneratorStalenessOtherSeries(t *testing.T) {
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: newTestDuration("stale"),
+		Errors:   newTestErrors("stale"),
+		Labels: []Label{
+			{Name: "stale", Values: []LabelValue{{Value: "a"}, {Value: "b"}}},
+		},
+		Staleness: Staleness{
+			Period: 4 * time.Second,
+			Gap:    2 * time.Second,
+		},
+	}
+
+	// The first request of the gap picks the stale series.
+	if !generator.isStale(2*time.Second, []string{"a"}) {
+		t.Fatalf("first series of the gap is not stale")
+	}
+
+	if generator.isStale(3*time.Second, []string{"b"}) {
+		t.Fatalf("other series is stale")
+	}
+
+	if !generator.isStale(3*time.Second, []string{"a"}) {
+		t.Fatalf("series is not stale for the whole gap")
+	}
+
+	// The next gap picks a new stale series.
+	if !generator.isStale(6*time.Second, []string{"b"}) {
+		t.Fatalf("first series of the next gap is not stale")
+	}
+
+	if generator.isStale(7*time.Second, []string{"a"}) {
+		t.Fatalf("series of the previous gap is stale")
+	}
+
+	if generator.isStale(4*time.Second, []string{"b"}) {
+		t.Fatalf("series is stale outside of a gap")
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tenant is a simulated tenant of a multi-tenant service.
+type Tenant struct {
+	Name string
+
+	// Weight is the share of the simulated requests assigned to the tenant,
+	// relative to the weights of the other tenants.
+	Weight int
+
+	// ErrorsPercentage, if not nil, overrides the errors percentage of the
+	// configuration for the requests of this tenant.
+	ErrorsPercentage *int
+}
+
+// ParseTenants parses a comma-separated list of tenants in the form
+// name:weight[:errors-percentage].
+func ParseTenants(value string) ([]Tenant, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var (
+		tenants []Tenant
+		names   = make(map[string]bool)
+	)
+
+	for _, part := range strings.Split(value, ",") {
+		tenant, err := parseTenant(part)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %v", part, err)
+		}
+
+		if names[tenant.Name] {
+			return nil, fmt.Errorf("tenant %q: duplicate name", part)
+		}
+
+		names[tenant.Name] = true
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants, nil
+}
+
+func parseTenant(value string) (Tenant, error) {
+	fields := strings.Split(strings.TrimSpace(value), ":")
+
+	if len(fields) < 2 || len(fields) > 3 {
+		return Tenant{}, fmt.Errorf("not in the form name:weight[:errors-percentage]")
+	}
+
+	if fields[0] == "" {
+		return Tenant{}, fmt.Errorf("empty name")
+	}
+
+	weight, err := strconv.Atoi(fields[1])
+	if err != nil || weight <= 0 {
+		return Tenant{}, fmt.Errorf("weight is not a positive number")
+	}
+
+	tenant := Tenant{
+		Name:   fields[0],
+		Weight: weight,
+	}
+
+	if len(fields) == 3 {
+		errorsPercentage, err := strconv.Atoi(fields[2])
+		if err != nil || errorsPercentage < 0 || errorsPercentage > 100 {
+			return Tenant{}, fmt.Errorf("errors percentage is not a valid percentage")
+		}
+
+		tenant.ErrorsPercentage = &errorsPercentage
+	}
+
+	return tenant, nil
+}
+
+func tenantsChooser(tenants []Tenant) weightedChooser {
+	weights := make([]int, len(tenants))
+
+	for i, tenant := range tenants {
+		weights[i] = tenant.Weight
+	}
+
+	return newWeightedChooser(weights)
+}
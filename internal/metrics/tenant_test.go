@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseTenants(t *testing.T) {
+	tenants, err := ParseTenants("acme:3,globex:1:50")
+	if err != nil {
+		t.Fatalf("parse tenants: %v", err)
+	}
+
+	errorsPercentage := 50
+
+	wanted := []Tenant{
+		{Name: "acme", Weight: 3},
+		{Name: "globex", Weight: 1, ErrorsPercentage: &errorsPercentage},
+	}
+
+	if diff := cmp.Diff(tenants, wanted); diff != "" {
+		t.Fatalf("invalid tenants:\n%s", diff)
+	}
+}
+
+func TestParseTenantsError(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{
+			name:  "no-weight",
+			value: "acme",
+		},
+		{
+			name:  "empty-name",
+			value: ":1",
+		},
+		{
+			name:  "invalid-weight",
+			value: "acme:boom",
+		},
+		{
+			name:  "zero-weight",
+			value: "acme:0",
+		},
+		{
+			name:  "invalid-errors-percentage",
+			value: "acme:1:101",
+		},
+		{
+			name:  "too-many-fields",
+			value: "acme:1:2:3",
+		},
+		{
+			name:  "duplicate",
+			value: "acme:1,acme:2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseTenants(test.value); err == nil {
+				t.Fatalf("no error returned")
+			}
+		})
+	}
+}
+
+func TestGeneratorTenants(t *testing.T) {
+	const requests = 1000
+
+	tenants, err := ParseTenants("acme:3:0,globex:1:100")
+	if err != nil {
+		t.Fatalf("parse tenants: %v", err)
+	}
+
+	var (
+		duration = newTestDuration(TenantLabel)
+		errors   = newTestErrors(TenantLabel)
+	)
+
+	generator := Generator{
+		Config:   newTestConfig(t),
+		Duration: duration,
+		Errors:   errors,
+		Tenants:  tenants,
+	}
+
+	for i := 0; i < requests; i++ {
+		generator.simulateRequest(0)
+	}
+
+	acmeRequests := histogramCount(t, duration, prometheus.Labels{TenantLabel: "acme"})
+	globexRequests := histogramCount(t, duration, prometheus.Labels{TenantLabel: "globex"})
+
+	if acmeRequests+globexRequests != requests {
+		t.Fatalf("invalid number of requests: %d", acmeRequests+globexRequests)
+	}
+
+	if acmeRequests < 650 || acmeRequests > 850 {
+		t.Fatalf("invalid number of requests for acme: %d", acmeRequests)
+	}
+
+	if got := testutil.CollectAndCount(errors); got != 1 {
+		t.Fatalf("invalid number of error series: %d", got)
+	}
+
+	if got := testutil.ToFloat64(errors.With(prometheus.Labels{TenantLabel: "globex", StatusClassLabel: statusClassServerError})); int(got) != globexRequests {
+		t.Fatalf("invalid number of errors for globex: wanted %d, got %v", globexRequests, got)
+	}
+}
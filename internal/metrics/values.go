@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseLabelValues parses a comma-separated list of distinct, non-empty label
+// values.
+func ParseLabelValues(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var (
+		values []string
+		seen   = make(map[string]bool)
+	)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+
+		if part == "" {
+			return nil, fmt.Errorf("empty value")
+		}
+
+		if seen[part] {
+			return nil, fmt.Errorf("duplicate value %q", part)
+		}
+
+		seen[part] = true
+		values = append(values, part)
+	}
+
+	return values, nil
+}
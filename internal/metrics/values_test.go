@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLabelValues(t *testing.T) {
+	values, err := ParseLabelValues("/users, /orders")
+	if err != nil {
+		t.Fatalf("parse label values: %v", err)
+	}
+
+	if diff := cmp.Diff(values, []string{"/users", "/orders"}); diff != "" {
+		t.Fatalf("invalid values:\n%s", diff)
+	}
+}
+
+func TestParseLabelValuesEmpty(t *testing.T) {
+	values, err := ParseLabelValues("")
+	if err != nil {
+		t.Fatalf("parse label values: %v", err)
+	}
+
+	if values != nil {
+		t.Fatalf("invalid values: %v", values)
+	}
+}
+
+func TestParseLabelValuesError(t *testing.T) {
+	for _, value := range []string{"a,,b", "a,b,a"} {
+		if _, err := ParseLabelValues(value); err == nil {
+			t.Fatalf("no error returned for %q", value)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config interface {
+	SetErrorsPercentage(value int) error
+}
+
+// ErrorsPercentage periodically fetches the errors percentage from an external
+// URL and applies it to the configuration. The URL must return a 200 response
+// whose body is an integer between 0 and 100. If fetching or applying the
+// value fails, the error is logged and the configuration is left unchanged.
+type ErrorsPercentage struct {
+	URL      string
+	Interval time.Duration
+	Config   Config
+
+	// Client is used to fetch the errors percentage. If Client is nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// Logger is used to log the errors. If Logger is nil, the default logger
+	// of the slog package is used.
+	Logger *slog.Logger
+}
+
+func (p *ErrorsPercentage) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.poll(ctx); err != nil {
+			p.logger().Error("poll errors percentage", "url", p.URL, "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+			continue
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *ErrorsPercentage) poll(ctx context.Context) error {
+	value, err := p.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("fetch: %v", err)
+	}
+
+	if err := p.Config.SetErrorsPercentage(value); err != nil {
+		return fmt.Errorf("set errors percentage: %v", err)
+	}
+
+	return nil
+}
+
+func (p *ErrorsPercentage) fetch(ctx context.Context) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("create request: %v", err)
+	}
+
+	response, err := p.client().Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("send request: %v", err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read body: %v", err)
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("not a number")
+	}
+
+	return value, nil
+}
+
+func (p *ErrorsPercentage) logger() *slog.Logger {
+	if p.Logger == nil {
+		return slog.Default()
+	}
+
+	return p.Logger
+}
+
+func (p *ErrorsPercentage) client() *http.Client {
+	if p.Client == nil {
+		return http.DefaultClient
+	}
+
+	return p.Client
+}
@@ -0,0 +1,130 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+)
+
+func TestErrorsPercentagePoll(t *testing.T) {
+	responses := []struct {
+		code   int
+		body   string
+		fails  bool
+		wanted int
+	}{
+		{code: http.StatusOK, body: "20\n", wanted: 20},
+		{code: http.StatusOK, body: "30", wanted: 30},
+		{code: http.StatusInternalServerError, body: "40", fails: true, wanted: 30},
+		{code: http.StatusOK, body: "boom", fails: true, wanted: 30},
+		{code: http.StatusOK, body: "101", fails: true, wanted: 30},
+		{code: http.StatusOK, body: "50", wanted: 50},
+	}
+
+	var next int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(responses[next].code)
+		fmt.Fprint(w, responses[next].body)
+	}))
+
+	defer server.Close()
+
+	var config limits.Config
+
+	poller := ErrorsPercentage{
+		URL:    server.URL,
+		Config: &config,
+	}
+
+	for i, response := range responses {
+		next = i
+
+		if err := poller.poll(context.Background()); (err != nil) != response.fails {
+			t.Fatalf("response %d: unexpected error: %v", i, err)
+		}
+
+		if got := config.ErrorsPercentage(); got != response.wanted {
+			t.Fatalf("response %d: invalid errors percentage: wanted %d, got %d", i, response.wanted, got)
+		}
+	}
+}
+
+type mockConfig struct {
+	values chan int
+}
+
+func (c mockConfig) SetErrorsPercentage(value int) error {
+	c.values <- value
+	return nil
+}
+
+func TestErrorsPercentageRun(t *testing.T) {
+	var value int32 = 20
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, atomic.LoadInt32(&value))
+	}))
+
+	defer server.Close()
+
+	config := mockConfig{
+		values: make(chan int),
+	}
+
+	poller := ErrorsPercentage{
+		URL:      server.URL,
+		Interval: time.Millisecond,
+		Config:   config,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- poller.Run(ctx)
+	}()
+
+	waitForErrorsPercentage(t, config, 20)
+	atomic.StoreInt32(&value, 40)
+	waitForErrorsPercentage(t, config, 40)
+
+	cancel()
+
+	// Unblock a poll that might be in progress.
+	go func() {
+		for range config.values {
+		}
+	}()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	close(config.values)
+}
+
+func waitForErrorsPercentage(t *testing.T, config mockConfig, wanted int) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case value := <-config.values:
+			if value == wanted {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("errors percentage never became %d", wanted)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net"
+	"sync"
+)
+
+// LimitListener returns a listener accepting at most n simultaneous
+// connections. Accept blocks while n connections are open, so the excess
+// connections wait in the backlog of the listener until a connection closes.
+func LimitListener(l net.Listener, n int) net.Listener {
+	return &limitListener{
+		Listener: l,
+		sem:      make(chan struct{}, n),
+		done:     make(chan struct{}),
+	}
+}
+
+type limitListener struct {
+	net.Listener
+
+	sem       chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+
+	return &limitListenerConn{Conn: conn, release: l.release}, nil
+}
+
+func (l *limitListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.done) })
+	return err
+}
+
+func (l *limitListener) release() {
+	<-l.sem
+}
+
+type limitListenerConn struct {
+	net.Conn
+
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitListenerConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	listener := LimitListener(inner, 1)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 2)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted <- conn
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+
+		defer conn.Close()
+	}
+
+	first := <-accepted
+
+	select {
+	case <-accepted:
+		t.Fatalf("connection accepted over the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection not accepted after a slot was released")
+	}
+}
+
+func TestLimitListenerClose(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	listener := LimitListener(inner, 1)
+
+	conn, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	defer conn.Close()
+
+	if _, err := listener.Accept(); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		_, err := listener.Accept()
+		errs <- err
+	}()
+
+	if err := listener.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatalf("no error returned")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("accept blocked after close")
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/francescomari/httprun"
+)
+
+type Gauge interface {
+	Set(float64)
+}
+
+// TimedShutdown is an HTTP server recording how long it takes to shut down.
+type TimedShutdown struct {
+	httprun.HTTPServer
+
+	// Duration is set to the duration of the shutdown, in seconds, when
+	// Shutdown returns.
+	Duration Gauge
+
+	// Logger, if not nil, logs the duration of the shutdown. The server
+	// exposing Duration is usually gone by then, so the log is the only way
+	// to observe it.
+	Logger *slog.Logger
+}
+
+func (s TimedShutdown) Shutdown(ctx context.Context) error {
+	start := time.Now()
+
+	defer func() {
+		duration := time.Since(start)
+
+		s.Duration.Set(duration.Seconds())
+
+		if s.Logger != nil {
+			s.Logger.Info("shutdown completed", "duration", duration)
+		}
+	}()
+
+	return s.HTTPServer.Shutdown(ctx)
+}
+
+// ClosableHTTPServer is an HTTP server that can be closed immediately.
+type ClosableHTTPServer interface {
+	httprun.HTTPServer
+	Close() error
+}
+
+// FastShutdown is an HTTP server that closes immediately when it's shut down,
+// without waiting for the active connections to become idle.
+type FastShutdown struct {
+	ClosableHTTPServer
+}
+
+func (s FastShutdown) Shutdown(ctx context.Context) error {
+	return s.ClosableHTTPServer.Close()
+}
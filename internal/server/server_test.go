@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockHTTPServer struct {
+	doShutdown func(ctx context.Context) error
+	doClose    func() error
+}
+
+func (s mockHTTPServer) ListenAndServe() error {
+	return nil
+}
+
+func (s mockHTTPServer) ListenAndServeTLS(certFile, keyFile string) error {
+	return nil
+}
+
+func (s mockHTTPServer) Serve(l net.Listener) error {
+	return nil
+}
+
+func (s mockHTTPServer) ServeTLS(l net.Listener, certFile, keyFile string) error {
+	return nil
+}
+
+func (s mockHTTPServer) Shutdown(ctx context.Context) error {
+	return s.doShutdown(ctx)
+}
+
+func (s mockHTTPServer) Close() error {
+	return s.doClose()
+}
+
+type mockGauge struct {
+	values []float64
+}
+
+func (g *mockGauge) Set(value float64) {
+	g.values = append(g.values, value)
+}
+
+func TestTimedShutdown(t *testing.T) {
+	var duration mockGauge
+
+	server := TimedShutdown{
+		HTTPServer: mockHTTPServer{
+			doShutdown: func(ctx context.Context) error {
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			},
+		},
+		Duration: &duration,
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if len(duration.values) != 1 {
+		t.Fatalf("invalid number of durations: %d", len(duration.values))
+	}
+
+	if got := duration.values[0]; got < 0.01 || got > 10 {
+		t.Fatalf("invalid duration: %v", got)
+	}
+}
+
+func TestTimedShutdownError(t *testing.T) {
+	var duration mockGauge
+
+	server := TimedShutdown{
+		HTTPServer: mockHTTPServer{
+			doShutdown: func(ctx context.Context) error {
+				return errors.New("error")
+			},
+		},
+		Duration: &duration,
+	}
+
+	if err := server.Shutdown(context.Background()); err == nil {
+		t.Fatalf("no error returned")
+	}
+
+	if len(duration.values) != 1 || duration.values[0] < 0 {
+		t.Fatalf("invalid durations: %v", duration.values)
+	}
+}
+
+func TestTimedShutdownLogger(t *testing.T) {
+	var (
+		duration mockGauge
+		output   bytes.Buffer
+	)
+
+	server := TimedShutdown{
+		HTTPServer: mockHTTPServer{
+			doShutdown: func(ctx context.Context) error {
+				return nil
+			},
+		},
+		Duration: &duration,
+		Logger:   slog.New(slog.NewTextHandler(&output, nil)),
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if !strings.Contains(output.String(), `msg="shutdown completed" duration=`) {
+		t.Fatalf("invalid log: %q", output.String())
+	}
+}
+
+func TestFastShutdown(t *testing.T) {
+	var closed bool
+
+	server := FastShutdown{
+		ClosableHTTPServer: mockHTTPServer{
+			doShutdown: func(ctx context.Context) error {
+				t.Fatalf("shutdown called")
+				return nil
+			},
+			doClose: func() error {
+				closed = true
+				return nil
+			},
+		},
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if !closed {
+		t.Fatalf("server not closed")
+	}
+}
+
+func TestFastShutdownError(t *testing.T) {
+	server := FastShutdown{
+		ClosableHTTPServer: mockHTTPServer{
+			doClose: func() error {
+				return errors.New("error")
+			},
+		},
+	}
+
+	if err := server.Shutdown(context.Background()); err == nil {
+		t.Fatalf("no error returned")
+	}
+}
@@ -0,0 +1,26 @@
+// Package version describes the build of the binary. The variables of this
+// package are set at build time with the -X flag of the linker, for example:
+//
+//	go build -ldflags "-X github.com/francescomari/metrics-generator/internal/version.Version=1.0.0"
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+var (
+	// Version is the version of the binary.
+	Version = "dev"
+
+	// Revision is the revision of the source code the binary was built from.
+	Revision = "unknown"
+
+	// BuildDate is the date the binary was built.
+	BuildDate = "unknown"
+)
+
+// String returns a human-readable description of the build.
+func String() string {
+	return fmt.Sprintf("version %s, revision %s, built %s with %s", Version, Revision, BuildDate, runtime.Version())
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,25 +20,131 @@ import (
 	"github.com/francescomari/metrics-generator/internal/api"
 	"github.com/francescomari/metrics-generator/internal/limits"
 	"github.com/francescomari/metrics-generator/internal/metrics"
+	"github.com/francescomari/metrics-generator/internal/poller"
+	"github.com/francescomari/metrics-generator/internal/server"
+	"github.com/francescomari/metrics-generator/internal/version"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
 )
 
-var requestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
-	Name: "metrics_generator_request_duration_seconds",
-	Help: "Request duration in seconds",
+// Names of the formats accepted by the -log-format flag.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Names of the distributions accepted by the -duration-distribution flag.
+const (
+	distributionUniform     = "uniform"
+	distributionNormal      = "normal"
+	distributionExponential = "exponential"
+)
+
+// Names of the traffic patterns accepted by the -rate-pattern flag.
+const (
+	ratePatternConstant = "constant"
+	ratePatternSine     = "sine"
+)
+
+// Names of the metric types accepted by the -duration-metric-type flag.
+const (
+	metricTypeHistogram = "histogram"
+	metricTypeSummary   = "summary"
+)
+
+// Names of the algorithms accepted by the -rand-source flag.
+const (
+	randSourceDefault  = "default"
+	randSourceSplitMix = "splitmix"
+)
+
+// slowStartFrom is the fraction of the request rate the slow start ramps up
+// from.
+const slowStartFrom = 0.1
+
+var requestDurationBuckets = prometheus.DefBuckets
+
+// registry holds every metric served by the metrics endpoint. The Go and the
+// process collectors are registered explicitly, so that the generator itself
+// can always be monitored.
+var registry = newRegistry()
+
+var factory = promauto.With(registry)
+
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGoCollector())
+	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return registry
+}
+
+// metricsHandler serves the metrics in registry, and instruments itself like
+// promhttp.Handler does for the default registry.
+func metricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(registry, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+var heartbeatTimestamp = factory.NewGauge(prometheus.GaugeOpts{
+	Name: "metrics_generator_heartbeat_timestamp_seconds",
+	Help: "Unix time of the last iteration of the metrics generator",
+})
+
+var durationMin = factory.NewGauge(prometheus.GaugeOpts{
+	Name: "metrics_generator_duration_min_seconds",
+	Help: "Configured minimum duration of the requests",
+})
+
+var durationMax = factory.NewGauge(prometheus.GaugeOpts{
+	Name: "metrics_generator_duration_max_seconds",
+	Help: "Configured maximum duration of the requests",
+})
+
+var errorsPercentage = factory.NewGauge(prometheus.GaugeOpts{
+	Name: "metrics_generator_errors_percentage",
+	Help: "Configured percentage of the requests that fail",
 })
 
-var requestErrorsCount = promauto.NewCounter(prometheus.CounterOpts{
-	Name: "metrics_generator_request_errors_count",
-	Help: "Number of errors observed in requests",
+var requestsInFlight = factory.NewGauge(prometheus.GaugeOpts{
+	Name: "metrics_generator_requests_in_flight",
+	Help: "Number of simulated requests that didn't complete yet",
+})
+
+var seenSeries = factory.NewGauge(prometheus.GaugeOpts{
+	Name: "metrics_generator_seen_series",
+	Help: "Number of distinct combinations of label values of the simulated requests since startup",
+})
+
+var connectionsActive = factory.NewGauge(prometheus.GaugeOpts{
+	Name: "metrics_generator_connections_active",
+	Help: "Number of simulated connections serving the requests",
+})
+
+var buildInfo = factory.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "metrics_generator_build_info",
+	Help: "Always 1, labeled with the version, the revision and the Go version of the build",
+}, []string{"version", "revision", "goversion"})
+
+var droppedObservations = factory.NewCounter(prometheus.CounterOpts{
+	Name: "metrics_generator_dropped_observations_total",
+	Help: "Number of observations dropped because of the maximum observation rate",
+})
+
+var shutdownDuration = factory.NewGauge(prometheus.GaugeOpts{
+	Name: "metrics_generator_shutdown_duration_seconds",
+	Help: "Duration of the last graceful shutdown of the API server",
+})
+
+var observationErrors = factory.NewCounter(prometheus.CounterOpts{
+	Name: "metrics_generator_observation_errors_total",
+	Help: "Number of observations that couldn't be recorded",
 })
 
 func main() {
 	if err := run(); err != nil {
-		log.Fatalf("error: %v", err)
+		slog.Error("exit", "err", err)
+		os.Exit(1)
 	}
 }
 
@@ -43,41 +154,434 @@ func run() error {
 	var g metricsGenerator
 
 	flag.StringVar(&g.address, "addr", ":8080", "The address to listen to")
-	flag.IntVar(&g.minDuration, "duration-min", 1, "Minimum request duration")
-	flag.IntVar(&g.maxDuration, "duration-max", 10, "Maximum request duration")
+	flag.StringVar(&g.metricsPath, "metrics-path", "/metrics", "The path the metrics are served from")
+	flag.DurationVar(&g.metricsDelay, "metrics-delay", 0, "How long to delay the responses of the metrics endpoint (0 to disable)")
+	flag.Float64Var(&g.minDuration, "duration-min", 1, "Minimum request duration in seconds")
+	flag.Float64Var(&g.maxDuration, "duration-max", 10, "Maximum request duration in seconds")
+	flag.Float64Var(&g.durationScale, "duration-scale", 1, "Factor every request duration is multiplied by")
 	flag.IntVar(&g.errorsPercentage, "errors-percentage", 10, "Which percentage of the requests will fail")
+	flag.IntVar(&g.requestRate, "request-rate", 1, "Number of requests per second")
+	flag.StringVar(&g.rateSteps, "rate-steps", "", "Comma-separated list of request rates and how long to hold them, in the form rate:duration")
+	flag.BoolVar(&g.rateStepsLoop, "rate-steps-loop", false, "Start again from the first step after the last one")
+	flag.StringVar(&g.ratePattern, "rate-pattern", ratePatternConstant, "Traffic pattern of the request rate (constant or sine)")
+	flag.DurationVar(&g.ratePeriod, "rate-period", 10*time.Minute, "Period of the sine traffic pattern")
+	flag.Float64Var(&g.rateMin, "rate-min", 1, "Minimum number of requests per second of the sine traffic pattern")
+	flag.Float64Var(&g.rateMax, "rate-max", 10, "Maximum number of requests per second of the sine traffic pattern")
+	flag.DurationVar(&g.slowStart, "slow-start", 0, "How long to ramp up the request rate after startup (0 to disable)")
+	flag.IntVar(&g.capacity, "capacity", 0, "Request rate past which the service is overloaded and fails more requests (0 to disable)")
+	flag.Float64Var(&g.clientErrorRatio, "client-error-ratio", 0, "Which fraction of the failed requests will be client (4xx) errors")
+	flag.StringVar(&g.endpoints, "endpoints", "", "Comma-separated list of endpoints of the simulated requests")
+	flag.StringVar(&g.methods, "methods", "", "Comma-separated list of HTTP methods of the simulated requests")
+	flag.StringVar(&g.tenants, "tenants", "", "Comma-separated list of tenants in the form name:weight[:errors-percentage]")
+	flag.StringVar(&g.successStatuses, "success-statuses", "", "Comma-separated list of status codes of the successful requests in the form code[:weight]")
+	flag.StringVar(&g.routes, "routes", "", "Comma-separated list of routes of the simulated requests in the form route[:weight]")
+	flag.StringVar(&g.labels, "labels", "", "Comma-separated list of custom labels in the form name=value[:weight]|value[:weight]...")
+	flag.DurationVar(&g.churnInterval, "churn-interval", 0, "How often the build_id label changes value (0 to disable)")
+	flag.DurationVar(&g.memoryLeakPeriod, "memory-leak-period", 0, "How long the simulated memory usage takes to grow before a simulated restart (0 to disable)")
+	flag.IntVar(&g.churnMaxValues, "churn-max-values", 100, "Maximum number of distinct values of the build_id label")
+	flag.Float64Var(&g.sloObjective, "slo-objective", 0, "Latency objective in seconds for computing the SLO compliance (0 to disable)")
+	flag.IntVar(&g.sloWindow, "slo-window", 100, "Number of recent requests the SLO compliance is computed on")
+	flag.IntVar(&g.rateHistorySize, "rate-history-size", 60, "Number of recent request rates, sampled every second, served by the request rate history endpoint")
+	flag.Uint64Var(&g.maxObservations, "max-observations", 0, "Number of recorded observations after which the generator stops (0 to disable)")
+	flag.BoolVar(&g.exitOnMaxObservations, "exit-on-max-observations", false, "Exit after the generator reached the maximum number of observations")
+	flag.Int64Var(&g.seed, "seed", 0, "Seed of the random number generator (0 to use the current time)")
+	flag.StringVar(&g.randSource, "rand-source", randSourceDefault, "Algorithm of the random number generator (default or splitmix)")
+	flag.BoolVar(&g.errorsDeterministic, "errors-deterministic", false, "Fail requests at regular intervals instead of at random")
+	flag.DurationVar(&g.errorsBurstPeriod, "errors-burst-period", 0, "How often a burst of errors happens (0 to disable)")
+	flag.DurationVar(&g.errorsBurstDuration, "errors-burst-duration", 10*time.Second, "How long a burst of errors lasts")
+	flag.IntVar(&g.errorsBurstPercentage, "errors-burst-percentage", 100, "Which percentage of the requests will fail during a burst of errors")
+	flag.DurationVar(&g.stalenessPeriod, "staleness-period", 0, "How often a series stops being updated for a while (0 to disable)")
+	flag.DurationVar(&g.stalenessGap, "staleness-gap", time.Minute, "How long a series stops being updated")
+	flag.BoolVar(&g.errorsFast, "errors-fast", false, "Observe a fixed small duration for failed requests")
+	flag.Float64Var(&g.errorsFastDuration, "errors-fast-duration", 0.01, "Duration in seconds observed for failed requests when -errors-fast is set")
+	flag.StringVar(&g.durationMetricType, "duration-metric-type", metricTypeHistogram, "Type of the duration metric (histogram or summary)")
+	flag.StringVar(&g.summaryObjectives, "summary-objectives", "0.5,0.9,0.99", "Comma-separated list of quantiles computed by the duration summary")
+	flag.StringVar(&g.durationDistribution, "duration-distribution", distributionUniform, "Distribution of the durations (uniform, normal or exponential)")
+	flag.Float64Var(&g.durationMean, "duration-mean", 0, "Mean in seconds of the normal distribution (0 for the middle of the duration interval)")
+	flag.Float64Var(&g.durationStdDev, "duration-stddev", 1, "Standard deviation in seconds of the normal distribution")
+	flag.Float64Var(&g.durationLambda, "duration-lambda", 1, "Rate parameter of the exponential distribution, the inverse of its mean in seconds")
+	flag.Float64Var(&g.durationJitter, "duration-jitter", 0, "Standard deviation in seconds of the noise added to every duration (0 to disable)")
+	flag.Float64Var(&g.outlierQuantile, "outlier-quantile", 0, "Quantile of the recent durations that bounds every duration (0 to disable)")
+	flag.IntVar(&g.outlierWindow, "outlier-window", 1000, "Number of recent durations the outlier quantile is computed on")
+	flag.Float64Var(&g.outlierMargin, "outlier-margin", 0, "Margin in seconds added to the outlier quantile")
+	flag.Float64Var(&g.connectionReuse, "connection-reuse", 1, "Number of requests per second served by every simulated connection")
+	flag.Float64Var(&g.maxObservationRate, "max-observation-rate", 0, "Maximum number of duration observations recorded per second (0 for no limit)")
+	flag.BoolVar(&g.invalidMetrics, "invalid-metrics", false, "Expose metrics violating the Prometheus conventions, for testing linters")
+	flag.BoolVar(&g.cacheObservers, "cache-observers", false, "Cache the metrics resolved for every combination of label values")
+	flag.DurationVar(&g.warmup, "warmup", 0, "How long the durations take to decay from the maximum duration to their normal values after startup (0 to disable)")
+	flag.IntVar(&g.bucketCycle, "bucket-cycle", 0, "Number of requests in which every histogram bucket receives at least one observation (0 to disable)")
+	flag.BoolVar(&g.sweepMode, "sweep-mode", false, "Observe the upper bound of every histogram bucket in order instead of random durations")
+	flag.StringVar(&g.durationQuantiles, "duration-quantiles", "", "Comma-separated list of quantiles of the duration interval to observe in order instead of random durations")
+	flag.StringVar(&g.errorsSourceURL, "errors-source-url", "", "URL to periodically fetch the errors percentage from")
+	flag.DurationVar(&g.errorsSourceInterval, "errors-source-interval", 10*time.Second, "How often to fetch the errors percentage from -errors-source-url")
+	flag.DurationVar(&g.readinessGrace, "readiness-grace", 0, "How long the process is not ready after a disruptive change of the configuration (0 to disable)")
+	flag.DurationVar(&g.startupDelay, "startup-delay", 0, "How long the health and readiness endpoints report that the process is unavailable after startup")
+	flag.IntVar(&g.maxConnections, "max-connections", 0, "Maximum number of simultaneous connections to the API server (0 for no limit)")
+	flag.BoolVar(&g.fastShutdown, "fast-shutdown", false, "Close the API server immediately on shutdown, without waiting for active requests")
+	flag.BoolVar(&g.enableShutdownEndpoint, "enable-shutdown-endpoint", false, "Enable the endpoint for shutting down the process")
+	flag.StringVar(&g.authUser, "auth-user", "", "User name required by authenticated endpoints")
+	flag.StringVar(&g.authPass, "auth-pass", "", "Password required by authenticated endpoints")
+	flag.BoolVar(&g.printVersion, "version", false, "Print the version and exit")
+	flag.StringVar(&g.logFormat, "log-format", logFormatText, "Format of the logs (text or json)")
+	flag.StringVar(&g.logLevel, "log-level", "info", "Minimum level of the logs (debug, info, warn or error)")
+	flag.BoolVar(&g.logRequests, "log-requests", false, "Log every request to the API server")
+	flag.StringVar(&g.configFile, "config", "", "JSON file with the initial configuration, overridden by the flags set on the command line")
+
+	flag.Usage = usage(flag.CommandLine)
+
+	if err := applyEnv(flag.CommandLine, os.Getenv); err != nil {
+		return err
+	}
+
 	flag.Parse()
 
+	if g.printVersion {
+		fmt.Println(version.String())
+		return nil
+	}
+
+	if g.configFile != "" {
+		if err := g.loadConfigFile(g.configFile, setFlags(flag.CommandLine)); err != nil {
+			return err
+		}
+	}
+
 	return g.run()
 }
 
 type metricsGenerator struct {
-	address          string
-	minDuration      int
-	maxDuration      int
-	errorsPercentage int
+	address                string
+	metricsPath            string
+	minDuration            float64
+	maxDuration            float64
+	durationScale          float64
+	errorsPercentage       int
+	requestRate            int
+	rateSteps              string
+	rateStepsLoop          bool
+	ratePattern            string
+	ratePeriod             time.Duration
+	rateMin                float64
+	rateMax                float64
+	slowStart              time.Duration
+	capacity               int
+	clientErrorRatio       float64
+	sweepMode              bool
+	tenants                string
+	endpoints              string
+	methods                string
+	routes                 string
+	labels                 string
+	churnInterval          time.Duration
+	churnMaxValues         int
+	sloObjective           float64
+	sloWindow              int
+	rateHistorySize        int
+	errorsFast             bool
+	errorsDeterministic    bool
+	errorsBurstPeriod      time.Duration
+	errorsBurstDuration    time.Duration
+	errorsBurstPercentage  int
+	stalenessPeriod        time.Duration
+	statusDistribution     limits.StatusDistribution
+	stalenessGap           time.Duration
+	seed                   int64
+	maxObservations        uint64
+	exitOnMaxObservations  bool
+	randSource             string
+	errorsFastDuration     float64
+	durationQuantiles      string
+	durationJitter         float64
+	durationDistribution   string
+	durationMetricType     string
+	summaryObjectives      string
+	durationMean           float64
+	durationStdDev         float64
+	durationLambda         float64
+	cacheObservers         bool
+	invalidMetrics         bool
+	readinessGrace         time.Duration
+	fastShutdown           bool
+	successStatuses        string
+	maxObservationRate     float64
+	logFormat              string
+	logLevel               string
+	logRequests            bool
+	logger                 *slog.Logger
+	memoryLeakPeriod       time.Duration
+	configFile             string
+	warmup                 time.Duration
+	maxConnections         int
+	printVersion           bool
+	bucketCycle            int
+	startupDelay           time.Duration
+	connectionReuse        float64
+	outlierQuantile        float64
+	outlierWindow          int
+	outlierMargin          float64
+	metricsDelay           time.Duration
+	errorsSourceURL        string
+	errorsSourceInterval   time.Duration
+	enableShutdownEndpoint bool
+	authUser               string
+	authPass               string
 }
 
 func (g *metricsGenerator) run() error {
+	if err := g.checkFlags(); err != nil {
+		return err
+	}
+
+	logger, err := buildLogger(os.Stderr, g.logFormat, g.logLevel)
+	if err != nil {
+		return err
+	}
+
+	g.logger = logger
+	slog.SetDefault(logger)
+
 	config, err := g.buildLimitsConfig()
 	if err != nil {
 		return err
 	}
 
+	if err := g.registerInvalidMetrics(registry); err != nil {
+		return err
+	}
+
+	setBuildInfo()
+
+	g.logger.Info("starting",
+		"version", version.Version,
+		"addr", g.address,
+		"duration_min", g.minDuration,
+		"duration_max", g.maxDuration,
+		"errors_percentage", g.errorsPercentage,
+		"request_rate", g.requestRate,
+	)
+
 	ctx, cancel := g.setupSignalHandler()
 	defer cancel()
 
-	if err := g.runServices(ctx, config); err != nil {
+	ctx, shutdown := context.WithCancel(ctx)
+	defer shutdown()
+
+	if err := g.runServices(ctx, shutdown, config); err != nil {
 		return fmt.Errorf("run services: %v", err)
 	}
 
 	return nil
 }
 
+// buildLogger returns a logger writing to w in the given format, discarding
+// the logs below the given level.
+func buildLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var l slog.Level
+
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %v", err)
+	}
+
+	options := slog.HandlerOptions{
+		Level: l,
+	}
+
+	switch format {
+	case logFormatText:
+		return slog.New(slog.NewTextHandler(w, &options)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, &options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format: %s", format)
+	}
+}
+
+func setBuildInfo() {
+	buildInfo.WithLabelValues(version.Version, version.Revision, runtime.Version()).Set(1)
+}
+
+func (g *metricsGenerator) checkFlags() error {
+	if !strings.HasPrefix(g.metricsPath, "/") {
+		return fmt.Errorf("the metrics path must start with a slash")
+	}
+
+	if g.enableShutdownEndpoint && (g.authUser == "" || g.authPass == "") {
+		return fmt.Errorf("the shutdown endpoint requires authentication")
+	}
+
+	if g.slowStart < 0 {
+		return fmt.Errorf("the slow start duration is less than zero")
+	}
+
+	if g.metricsDelay < 0 {
+		return fmt.Errorf("the metrics delay is less than zero")
+	}
+
+	if g.maxConnections < 0 {
+		return fmt.Errorf("the maximum number of connections is less than zero")
+	}
+
+	if g.startupDelay < 0 {
+		return fmt.Errorf("the startup delay is less than zero")
+	}
+
+	if g.readinessGrace < 0 {
+		return fmt.Errorf("the readiness grace window is less than zero")
+	}
+
+	if g.memoryLeakPeriod < 0 {
+		return fmt.Errorf("the memory leak period is less than zero")
+	}
+
+	if g.churnInterval < 0 {
+		return fmt.Errorf("the churn interval is less than zero")
+	}
+
+	if g.churnMaxValues <= 0 {
+		return fmt.Errorf("the maximum number of churn values is less than or equal to zero")
+	}
+
+	if g.sloObjective < 0 {
+		return fmt.Errorf("the SLO objective is less than zero")
+	}
+
+	if g.sloWindow <= 0 {
+		return fmt.Errorf("the SLO window is less than or equal to zero")
+	}
+
+	if g.rateHistorySize <= 0 {
+		return fmt.Errorf("the size of the request rate history is less than or equal to zero")
+	}
+
+	if g.errorsBurstPeriod < 0 {
+		return fmt.Errorf("the period of the bursts of errors is less than zero")
+	}
+
+	if g.errorsBurstPeriod > 0 && (g.errorsBurstDuration <= 0 || g.errorsBurstDuration > g.errorsBurstPeriod) {
+		return fmt.Errorf("the duration of the bursts of errors is not between zero and their period")
+	}
+
+	if g.errorsBurstPercentage < 0 || g.errorsBurstPercentage > 100 {
+		return fmt.Errorf("the errors percentage of the bursts of errors is not a valid percentage")
+	}
+
+	if g.stalenessPeriod < 0 {
+		return fmt.Errorf("the period of the staleness gaps is less than zero")
+	}
+
+	if g.stalenessPeriod > 0 && (g.stalenessGap <= 0 || g.stalenessGap > g.stalenessPeriod) {
+		return fmt.Errorf("the duration of the staleness gaps is not between zero and their period")
+	}
+
+	if g.errorsFast && g.errorsFastDuration <= 0 {
+		return fmt.Errorf("the duration of fast errors is less than or equal to zero")
+	}
+
+	if g.errorsSourceURL != "" && g.errorsSourceInterval <= 0 {
+		return fmt.Errorf("the errors source interval is less than or equal to zero")
+	}
+
+	if g.randSource != randSourceDefault && g.randSource != randSourceSplitMix {
+		return fmt.Errorf("invalid random source: %s", g.randSource)
+	}
+
+	if g.ratePattern != ratePatternConstant && g.ratePattern != ratePatternSine {
+		return fmt.Errorf("invalid rate pattern: %s", g.ratePattern)
+	}
+
+	if g.ratePattern == ratePatternSine {
+		if g.rateSteps != "" {
+			return fmt.Errorf("the sine rate pattern and rate steps are mutually exclusive")
+		}
+
+		if g.ratePeriod <= 0 {
+			return fmt.Errorf("the rate period is less than or equal to zero")
+		}
+
+		if g.rateMin <= 0 {
+			return fmt.Errorf("the minimum rate is less than or equal to zero")
+		}
+
+		if g.rateMax < g.rateMin {
+			return fmt.Errorf("the maximum rate is less than the minimum rate")
+		}
+	}
+
+	if g.durationMetricType != metricTypeHistogram && g.durationMetricType != metricTypeSummary {
+		return fmt.Errorf("invalid duration metric type: %s", g.durationMetricType)
+	}
+
+	switch g.durationDistribution {
+	case distributionUniform, distributionNormal, distributionExponential:
+	default:
+		return fmt.Errorf("invalid duration distribution: %s", g.durationDistribution)
+	}
+
+	if g.durationMean < 0 {
+		return fmt.Errorf("the mean duration is less than zero")
+	}
+
+	if g.durationStdDev <= 0 {
+		return fmt.Errorf("the standard deviation of the durations is less than or equal to zero")
+	}
+
+	if g.durationJitter < 0 {
+		return fmt.Errorf("the duration jitter is less than zero")
+	}
+
+	if g.outlierQuantile < 0 || g.outlierQuantile > 1 {
+		return fmt.Errorf("the outlier quantile is not between 0 and 1")
+	}
+
+	if g.maxObservationRate < 0 {
+		return fmt.Errorf("the maximum observation rate is less than zero")
+	}
+
+	if g.connectionReuse <= 0 {
+		return fmt.Errorf("the connection reuse factor is less than or equal to zero")
+	}
+
+	if g.outlierWindow <= 0 {
+		return fmt.Errorf("the outlier window is less than or equal to zero")
+	}
+
+	if g.outlierMargin < 0 {
+		return fmt.Errorf("the outlier margin is less than zero")
+	}
+
+	if g.warmup < 0 {
+		return fmt.Errorf("the warmup is less than zero")
+	}
+
+	if g.bucketCycle < 0 {
+		return fmt.Errorf("the bucket cycle is less than zero")
+	}
+
+	if g.sweepMode && g.durationQuantiles != "" {
+		return fmt.Errorf("sweep mode and duration quantiles are mutually exclusive")
+	}
+
+	return nil
+}
+
+// registerInvalidMetrics registers the metrics violating the Prometheus
+// conventions, if enabled.
+func (g *metricsGenerator) registerInvalidMetrics(r prometheus.Registerer) error {
+	if !g.invalidMetrics {
+		return nil
+	}
+
+	if err := r.Register(metrics.InvalidCollector{}); err != nil {
+		return fmt.Errorf("register invalid metrics: %v", err)
+	}
+
+	return nil
+}
+
 func (g *metricsGenerator) buildLimitsConfig() (*limits.Config, error) {
 	var config limits.Config
 
-	if err := config.SetDurationInterval(g.minDuration, g.maxDuration); err != nil {
+	if err := config.SetDurationIntervalSeconds(g.minDuration, g.maxDuration); err != nil {
 		return nil, fmt.Errorf("set max duration: %v", err)
 	}
 
@@ -85,6 +589,33 @@ func (g *metricsGenerator) buildLimitsConfig() (*limits.Config, error) {
 		return nil, fmt.Errorf("set errors percentage: %v", err)
 	}
 
+	if err := config.SetCapacity(g.capacity); err != nil {
+		return nil, fmt.Errorf("set capacity: %v", err)
+	}
+
+	if err := config.SetClientErrorRatio(g.clientErrorRatio); err != nil {
+		return nil, fmt.Errorf("set client error ratio: %v", err)
+	}
+
+	if err := config.SetRequestRate(g.requestRate); err != nil {
+		return nil, fmt.Errorf("set request rate: %v", err)
+	}
+
+	if err := config.SetDurationScale(g.durationScale); err != nil {
+		return nil, fmt.Errorf("set duration scale: %v", err)
+	}
+
+	if err := config.SetDurationLambda(g.durationLambda); err != nil {
+		return nil, fmt.Errorf("set duration lambda: %v", err)
+	}
+
+	if err := config.SetStatusDistribution(g.statusDistribution); err != nil {
+		return nil, fmt.Errorf("set status distribution: %v", err)
+	}
+
+	metrics.ExportDurationInterval(&config, durationMin, durationMax)
+	metrics.ExportErrorsPercentage(&config, errorsPercentage)
+
 	return &config, nil
 }
 
@@ -92,58 +623,435 @@ func (g *metricsGenerator) setupSignalHandler() (context.Context, context.Cancel
 	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 }
 
-func (g *metricsGenerator) runServices(ctx context.Context, config *limits.Config) error {
+func (g *metricsGenerator) runServices(ctx context.Context, shutdown func(), config *limits.Config) error {
+	// If the process is asked to exit before the services start, there is
+	// nothing to shut down. Don't start the services at all, instead of
+	// binding the address and simulating requests only to stop immediately.
+	if ctx.Err() != nil {
+		return nil
+	}
+
 	group, ctx := errgroup.WithContext(ctx)
 
+	history := metrics.RateHistory{
+		Size: g.rateHistorySize,
+	}
+
+	var readiness metrics.Readiness
+
 	group.Go(func() error {
-		return g.runMetricsGenerator(ctx, config)
+		if err := g.runMetricsGenerator(ctx, config, &history, &readiness); err != nil {
+			return err
+		}
+
+		// The generator returns without an error either when it reaches the
+		// maximum number of observations or when the services are shutting
+		// down, in which case shutting down again is harmless.
+		if g.maxObservations > 0 && g.exitOnMaxObservations {
+			shutdown()
+		}
+
+		return nil
 	})
 
 	group.Go(func() error {
-		return g.runAPIServer(ctx, config)
+		return g.runAPIServer(ctx, shutdown, config, &history, &readiness)
 	})
 
+	if g.errorsSourceURL != "" {
+		group.Go(func() error {
+			return g.runErrorsPercentagePoller(ctx, config)
+		})
+	}
+
 	return group.Wait()
 }
 
-func (g *metricsGenerator) runMetricsGenerator(ctx context.Context, config *limits.Config) error {
+func (g *metricsGenerator) runMetricsGenerator(ctx context.Context, config *limits.Config, history *metrics.RateHistory, readiness *metrics.Readiness) error {
+	tenants, err := metrics.ParseTenants(g.tenants)
+	if err != nil {
+		return fmt.Errorf("parse tenants: %v", err)
+	}
+
+	endpoints, err := metrics.ParseLabelValues(g.endpoints)
+	if err != nil {
+		return fmt.Errorf("parse endpoints: %v", err)
+	}
+
+	methods, err := metrics.ParseLabelValues(g.methods)
+	if err != nil {
+		return fmt.Errorf("parse methods: %v", err)
+	}
+
+	routes, err := metrics.ParseRoutes(g.routes)
+	if err != nil {
+		return fmt.Errorf("parse routes: %v", err)
+	}
+
+	labels, err := metrics.ParseLabels(g.labels)
+	if err != nil {
+		return fmt.Errorf("parse labels: %v", err)
+	}
+
+	successStatuses, err := metrics.ParseSuccessStatuses(g.successStatuses)
+	if err != nil {
+		return fmt.Errorf("parse success statuses: %v", err)
+	}
+
 	generator := metrics.Generator{
-		Config:   config,
-		Duration: requestDuration,
-		Errors:   requestErrorsCount,
+		Config:              config,
+		MaxObservations:     g.maxObservations,
+		Heartbeat:           heartbeatTimestamp,
+		InFlight:            requestsInFlight,
+		SeenSeries:          seenSeries,
+		MaxObservationRate:  g.maxObservationRate,
+		DroppedObservations: droppedObservations,
+		Logger:              g.logger,
+		SuccessStatuses:     successStatuses,
+		Connections:         connectionsActive,
+		ConnectionReuse:     g.connectionReuse,
+		RateHistory:         history,
+		Readiness:           readiness,
+		ObservationErrors:   observationErrors,
+		Tenants:             tenants,
+		Endpoints:           endpoints,
+		Methods:             methods,
+		Routes:              routes,
+		Labels:              labels,
+		CacheObservers:      g.cacheObservers,
+		DeterministicErrors: g.errorsDeterministic,
+		Churn: metrics.Churn{
+			Interval:  g.churnInterval,
+			MaxValues: g.churnMaxValues,
+		},
+		ErrorBurst: metrics.ErrorBurst{
+			Period:           g.errorsBurstPeriod,
+			Duration:         g.errorsBurstDuration,
+			ErrorsPercentage: g.errorsBurstPercentage,
+		},
+		Staleness: metrics.Staleness{
+			Period: g.stalenessPeriod,
+			Gap:    g.stalenessGap,
+		},
+	}
+
+	if g.errorsFast {
+		generator.FastErrorDuration = g.errorsFastDuration
+	}
+
+	// The memory usage is registered only if the leak is simulated, so that
+	// it doesn't look like a real measurement otherwise.
+	if g.memoryLeakPeriod > 0 {
+		generator.MemoryLeak = metrics.MemoryLeak{
+			Period: g.memoryLeakPeriod,
+			Usage: factory.NewGauge(prometheus.GaugeOpts{
+				Name: "metrics_generator_memory_usage_ratio",
+				Help: "Simulated memory usage of a leaking process",
+			}),
+		}
+	}
+
+	if g.bucketCycle > 0 {
+		generator.BucketGuarantee = metrics.BucketGuarantee{
+			Buckets: requestDurationBuckets,
+			Cycle:   g.bucketCycle,
+		}
 	}
 
-	if err := g.handleMetricsGeneratorError(generator.Run(ctx)); err != nil {
+	if g.sloObjective > 0 {
+		generator.SLO = &metrics.SLO{
+			Objective: g.sloObjective,
+			Window:    g.sloWindow,
+		}
+		generator.SLOCompliance = factory.NewGauge(prometheus.GaugeOpts{
+			Name: "metrics_generator_slo_compliance_ratio",
+			Help: "Fraction of the recent requests that succeeded within the latency objective",
+		})
+	}
+
+	labelNames := generator.LabelNames()
+
+	duration, err := g.buildDurationVec(labelNames)
+	if err != nil {
+		return err
+	}
+
+	generator.Duration = duration
+
+	generator.Errors = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "metrics_generator_request_errors_count",
+		Help: "Number of errors observed in requests",
+	}, append(labelNames, metrics.StatusClassLabel))
+
+	generator.Requests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "metrics_generator_requests_total",
+		Help: "Number of simulated requests",
+	}, generator.RequestsLabelNames())
+
+	rate, err := g.buildRateSource(config)
+	if err != nil {
+		return err
+	}
+
+	generator.Rate = rate
+
+	generator.Rand = g.buildRand()
+
+	sampler, err := g.buildSampler(config, generator.Rand)
+	if err != nil {
+		return err
+	}
+
+	generator.Sampler = sampler
+
+	if err := g.handleContextError(generator.Run(ctx)); err != nil {
 		return fmt.Errorf("metrics generator: %v", err)
 	}
 
 	return nil
 }
 
-func (g *metricsGenerator) runAPIServer(ctx context.Context, config *limits.Config) error {
+func (g *metricsGenerator) buildDurationVec(labels []string) (metrics.HistogramVec, error) {
+	if g.durationMetricType == metricTypeSummary {
+		objectives, err := metrics.ParseObjectives(g.summaryObjectives)
+		if err != nil {
+			return nil, fmt.Errorf("parse summary objectives: %v", err)
+		}
+
+		return factory.NewSummaryVec(prometheus.SummaryOpts{
+			Name:       "metrics_generator_request_duration_seconds",
+			Help:       "Request duration in seconds",
+			Objectives: objectives,
+		}, labels), nil
+	}
+
+	return factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "metrics_generator_request_duration_seconds",
+		Help:    "Request duration in seconds",
+		Buckets: requestDurationBuckets,
+	}, labels), nil
+}
+
+func (g *metricsGenerator) buildRand() *rand.Rand {
+	if g.seed == 0 && g.randSource == randSourceDefault {
+		return nil
+	}
+
+	seed := g.seed
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	if g.randSource == randSourceSplitMix {
+		return rand.New(metrics.NewSplitMixSource(seed))
+	}
+
+	return rand.New(rand.NewSource(seed))
+}
+
+func (g *metricsGenerator) buildRateSource(config *limits.Config) (metrics.RateSource, error) {
+	var rate metrics.RateSource
+
+	if g.rateSteps != "" {
+		steps, err := metrics.ParseSteps(g.rateSteps)
+		if err != nil {
+			return nil, fmt.Errorf("parse rate steps: %v", err)
+		}
+
+		rate = metrics.StepRate{
+			Steps: steps,
+			Loop:  g.rateStepsLoop,
+		}
+	}
+
+	if g.ratePattern == ratePatternSine {
+		rate = metrics.SineRate{
+			Min:    g.rateMin,
+			Max:    g.rateMax,
+			Period: g.ratePeriod,
+		}
+	}
+
+	if g.slowStart > 0 {
+		if rate == nil {
+			rate = metrics.ConfigRate{Config: config}
+		}
+
+		rate = metrics.SlowStart{
+			Source: rate,
+			Window: g.slowStart,
+			From:   slowStartFrom,
+		}
+	}
+
+	return rate, nil
+}
+
+func (g *metricsGenerator) buildSampler(config *limits.Config, r *rand.Rand) (metrics.Sampler, error) {
+	var sampler metrics.Sampler = metrics.UniformSampler{
+		Config: config,
+		Rand:   r,
+	}
+
+	switch g.durationDistribution {
+	case distributionNormal:
+		sampler = metrics.NormalSampler{
+			Config: config,
+			Mean:   g.durationMean,
+			StdDev: g.durationStdDev,
+			Rand:   r,
+		}
+	case distributionExponential:
+		sampler = metrics.ExponentialSampler{
+			Config: config,
+			Rand:   r,
+		}
+	}
+
+	if g.sweepMode {
+		sampler = &metrics.SweepSampler{
+			Buckets: requestDurationBuckets,
+		}
+	}
+
+	if g.durationQuantiles != "" {
+		quantiles, err := metrics.ParseQuantiles(g.durationQuantiles)
+		if err != nil {
+			return nil, fmt.Errorf("parse duration quantiles: %v", err)
+		}
+
+		sampler = &metrics.QuantileSampler{
+			Config:    config,
+			Quantiles: quantiles,
+		}
+	}
+
+	if g.durationJitter > 0 {
+		sampler = metrics.JitterSampler{
+			Sampler: sampler,
+			StdDev:  g.durationJitter,
+			Rand:    r,
+		}
+	}
+
+	if g.outlierQuantile > 0 {
+		sampler = &metrics.OutlierSampler{
+			Sampler:  sampler,
+			Window:   g.outlierWindow,
+			Quantile: g.outlierQuantile,
+			Margin:   g.outlierMargin,
+		}
+	}
+
+	if g.warmup > 0 {
+		sampler = &metrics.WarmupSampler{
+			Sampler: sampler,
+			Config:  config,
+			Warmup:  g.warmup,
+		}
+	}
+
+	// Without a Sampler, the Generator samples uniformly from the snapshot of
+	// the configuration it takes for every request, instead of reading the
+	// configuration again.
+	if _, ok := sampler.(metrics.UniformSampler); ok {
+		return nil, nil
+	}
+
+	return sampler, nil
+}
+
+func (g *metricsGenerator) runErrorsPercentagePoller(ctx context.Context, config *limits.Config) error {
+	p := poller.ErrorsPercentage{
+		URL:      g.errorsSourceURL,
+		Interval: g.errorsSourceInterval,
+		Config:   config,
+		Logger:   g.logger,
+	}
+
+	if err := g.handleContextError(p.Run(ctx)); err != nil {
+		return fmt.Errorf("errors percentage poller: %v", err)
+	}
+
+	return nil
+}
+
+func (g *metricsGenerator) runAPIServer(ctx context.Context, shutdown func(), config *limits.Config, history *metrics.RateHistory, readiness *metrics.Readiness) error {
 	handler := api.Handler{
-		Config:  config,
-		Metrics: promhttp.Handler(),
+		Config:         config,
+		RateHistory:    history,
+		ReadinessGrace: g.readinessGrace,
+		Readiness:      readiness,
+		StartupDelay:   g.startupDelay,
+		Logger:         g.requestLogger(),
+		Started:        time.Now(),
+		Metrics:        metricsHandler(),
+		Gatherer:       registry,
+		MetricsPath:    g.metricsPath,
+		MetricsDelay:   g.metricsDelay,
+		Flags:          flag.CommandLine,
+		AuthUser:       g.authUser,
+		AuthPass:       g.authPass,
 	}
 
-	server := http.Server{
+	if g.enableShutdownEndpoint {
+		handler.Shutdown = shutdown
+	}
+
+	httpServer := http.Server{
 		Addr:    g.address,
 		Handler: &handler,
 	}
 
+	var runnable httprun.HTTPServer = &httpServer
+
+	if g.fastShutdown {
+		runnable = server.FastShutdown{
+			ClosableHTTPServer: &httpServer,
+		}
+	}
+
 	runServer := httprun.Server{
-		HTTPServer:      &server,
+		HTTPServer: server.TimedShutdown{
+			HTTPServer: runnable,
+			Duration:   shutdownDuration,
+			Logger:     g.logger,
+		},
 		ShutdownTimeout: time.Second,
 	}
 
-	if err := runServer.ListenAndServe(ctx); err != nil {
+	if err := g.serveAPI(ctx, runServer); err != nil {
 		return fmt.Errorf("API server: %v", err)
 	}
 
 	return nil
 }
 
-func (g *metricsGenerator) handleMetricsGeneratorError(err error) error {
+func (g *metricsGenerator) serveAPI(ctx context.Context, runServer httprun.Server) error {
+	if g.maxConnections == 0 {
+		return runServer.ListenAndServe(ctx)
+	}
+
+	listener, err := net.Listen("tcp", g.address)
+	if err != nil {
+		return err
+	}
+
+	return runServer.Serve(ctx, server.LimitListener(listener, g.maxConnections))
+}
+
+// requestLogger returns the logger for the requests to the API server, or nil
+// if the requests must not be logged.
+func (g *metricsGenerator) requestLogger() *slog.Logger {
+	if !g.logRequests {
+		return nil
+	}
+
+	return g.logger
+}
+
+func (g *metricsGenerator) handleContextError(err error) error {
 	switch err {
 	case context.Canceled:
 		return nil
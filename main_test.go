@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/francescomari/metrics-generator/internal/limits"
+	"github.com/francescomari/metrics-generator/internal/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestRunServicesCancelledContext(t *testing.T) {
+	// Occupy an address, so that the API server fails if it tries to bind it.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	defer listener.Close()
+
+	g := metricsGenerator{
+		address: listener.Addr().String(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := g.runServices(ctx, cancel, &limits.Config{}); err != nil {
+		t.Fatalf("run services: %v", err)
+	}
+}
+
+func TestMetricsHandlerRuntimeMetrics(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("invalid status code: %d", recorder.Code)
+	}
+
+	var parser expfmt.TextParser
+
+	families, err := parser.TextToMetricFamilies(recorder.Body)
+	if err != nil {
+		t.Fatalf("parse metrics: %v", err)
+	}
+
+	if _, ok := families["go_goroutines"]; !ok {
+		t.Fatalf("go_goroutines not found")
+	}
+}
+
+func TestRegisterInvalidMetrics(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		g := metricsGenerator{
+			invalidMetrics: enabled,
+		}
+
+		registry := newRegistry()
+
+		if err := g.registerInvalidMetrics(registry); err != nil {
+			t.Fatalf("register invalid metrics: %v", err)
+		}
+
+		recorder := httptest.NewRecorder()
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		var parser expfmt.TextParser
+
+		families, err := parser.TextToMetricFamilies(recorder.Body)
+		if err != nil {
+			t.Fatalf("parse metrics: %v", err)
+		}
+
+		for _, name := range []string{"metricsGenerator_invalidCamelCase", "metrics_generator_invalid_empty_help"} {
+			if _, ok := families[name]; ok != enabled {
+				t.Fatalf("invalid presence of %s with invalid metrics enabled=%v", name, enabled)
+			}
+		}
+	}
+}
+
+func TestSetBuildInfo(t *testing.T) {
+	setBuildInfo()
+
+	if got := testutil.ToFloat64(buildInfo.WithLabelValues(version.Version, version.Revision, runtime.Version())); got != 1 {
+		t.Fatalf("invalid build info: %v", got)
+	}
+
+	if got := testutil.CollectAndCount(buildInfo, "metrics_generator_build_info"); got != 1 {
+		t.Fatalf("invalid number of build info series: %d", got)
+	}
+}
+
+func TestBuildLoggerJSON(t *testing.T) {
+	var output bytes.Buffer
+
+	logger, err := buildLogger(&output, logFormatJSON, "info")
+	if err != nil {
+		t.Fatalf("build logger: %v", err)
+	}
+
+	logger.Debug("hidden")
+	logger.Info("starting", "addr", ":8080", "errors_percentage", 10)
+
+	var entry map[string]interface{}
+
+	if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	for _, key := range []string{"time", "level", "msg", "addr", "errors_percentage"} {
+		if _, ok := entry[key]; !ok {
+			t.Fatalf("missing key %q in %s", key, output.String())
+		}
+	}
+
+	if entry["msg"] != "starting" || entry["addr"] != ":8080" {
+		t.Fatalf("invalid log entry: %s", output.String())
+	}
+}
+
+func TestBuildLoggerError(t *testing.T) {
+	if _, err := buildLogger(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Fatalf("no error returned for an invalid format")
+	}
+
+	if _, err := buildLogger(&bytes.Buffer{}, logFormatText, "loud"); err == nil {
+		t.Fatalf("no error returned for an invalid level")
+	}
+}
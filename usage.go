@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// hiddenFlags are the names of the flags omitted from the usage message. They
+// are meant for testing, and can still be set like any other flag.
+var hiddenFlags = map[string]bool{
+	"invalid-metrics": true,
+}
+
+// usage returns a function printing the usage message of fs, like the default
+// one, without the flags in hiddenFlags.
+func usage(fs *flag.FlagSet) func() {
+	return func() {
+		visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		visible.SetOutput(fs.Output())
+
+		fs.VisitAll(func(f *flag.Flag) {
+			if hiddenFlags[f.Name] {
+				return
+			}
+
+			visible.Var(f.Value, f.Name, f.Usage)
+
+			// The default value is computed from the current value of the
+			// flag, which might have been set already.
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		})
+
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		visible.PrintDefaults()
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestUsageHiddenFlags(t *testing.T) {
+	var output bytes.Buffer
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&output)
+	fs.Bool("invalid-metrics", false, "Hidden flag")
+	fs.String("visible", "default", "Visible flag")
+	fs.Usage = usage(fs)
+
+	if err := fs.Parse([]string{"-visible", "value", "-help"}); err != flag.ErrHelp {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	if strings.Contains(output.String(), "invalid-metrics") {
+		t.Fatalf("hidden flag in usage:\n%s", output.String())
+	}
+
+	if !strings.Contains(output.String(), "-visible string") || !strings.Contains(output.String(), `(default "default")`) {
+		t.Fatalf("visible flag not in usage:\n%s", output.String())
+	}
+
+	// Hidden flags can still be set.
+	if err := fs.Parse([]string{"-invalid-metrics"}); err != nil {
+		t.Fatalf("parse hidden flag: %v", err)
+	}
+}